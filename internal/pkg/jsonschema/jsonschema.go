@@ -0,0 +1,270 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a minimal JSON Schema representation
+// Only the keywords needed to describe our output structs are supported:
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+// ErrNotStrict is returned for schemas that can't be used in strict mode:
+var ErrNotStrict = errors.New("schema not supported in strict mode")
+
+// ValidationError describes a value that doesn't match the schema:
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Reflect derives a schema from a Go value, usually a pointer to a struct
+// Fields follow the json tag names, fields without omitempty are required and
+// the "jsonschema" tag accepts: "-", "description=...", "enum=a|b", "minimum=0" and "maximum=1":
+func Reflect(v any) *Schema {
+	return reflectType(reflect.TypeOf(v))
+}
+
+func reflectType(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: reflectType(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.Struct:
+		return reflectStruct(t)
+	}
+	return &Schema{}
+}
+
+func reflectStruct(t reflect.Type) *Schema {
+	noAdditional := false
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		Required:             make([]string, 0),
+		AdditionalProperties: &noAdditional,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("jsonschema") == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := reflectType(field.Type)
+		applyTag(property, field.Tag.Get("jsonschema"))
+		s.Properties[name] = property
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// applyTag parses the jsonschema tag options into the schema:
+func applyTag(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, option := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "description":
+			s.Description = value
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+		case "minimum":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s.Minimum = &f
+			}
+		case "maximum":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				s.Maximum = &f
+			}
+		}
+	}
+}
+
+// Strict returns a copy of the schema for the OpenAI strict mode, where every property must be
+// required and objects can't have additional properties. Objects without a fixed set of
+// properties -Go maps- aren't supported:
+func (s *Schema) Strict() (*Schema, error) {
+	return s.strict("$")
+}
+
+func (s *Schema) strict(path string) (*Schema, error) {
+	strict := *s
+	if s.Items != nil {
+		items, err := s.Items.strict(path + "[]")
+		if err != nil {
+			return nil, err
+		}
+		strict.Items = items
+	}
+	if s.Type != "object" {
+		return &strict, nil
+	}
+	if s.Properties == nil || s.AdditionalProperties == nil || *s.AdditionalProperties {
+		return nil, fmt.Errorf("%w: %s: objects must have fixed properties", ErrNotStrict, path)
+	}
+	strict.Properties = make(map[string]*Schema, len(s.Properties))
+	strict.Required = append([]string{}, s.Required...)
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, err := s.Properties[name].strict(path + "." + name)
+		if err != nil {
+			return nil, err
+		}
+		strict.Properties[name] = property
+		if !contains(strict.Required, name) {
+			strict.Required = append(strict.Required, name)
+		}
+	}
+	return &strict, nil
+}
+
+// String returns the schema as indented JSON, useful for prompts:
+func (s *Schema) String() string {
+	rawSchema, _ := json.MarshalIndent(s, "", "  ")
+	return string(rawSchema)
+}
+
+// Validate checks that the given JSON document matches the schema:
+func (s *Schema) Validate(rawJSON []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(rawJSON))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Path: "$", Message: "invalid JSON: " + err.Error()}
+	}
+	return s.validate("$", value)
+}
+
+func (s *Schema) validate(path string, value any) error {
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return &ValidationError{Path: path, Message: "expected an object"}
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return &ValidationError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return &ValidationError{Path: path, Message: fmt.Sprintf("unexpected property %q", name)}
+				}
+				continue
+			}
+			if err := property.validate(path+"."+name, object[name]); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return &ValidationError{Path: path, Message: "expected an array"}
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range array {
+			if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return &ValidationError{Path: path, Message: "expected a string"}
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("%q is not one of %s", str, strings.Join(s.Enum, ", "))}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &ValidationError{Path: path, Message: "expected a boolean"}
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{Path: path, Message: "expected a " + s.Type}
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				return &ValidationError{Path: path, Message: "expected an integer"}
+			}
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return &ValidationError{Path: path, Message: "invalid number"}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("%v is lower than %v", f, *s.Minimum)}
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return &ValidationError{Path: path, Message: fmt.Sprintf("%v is greater than %v", f, *s.Maximum)}
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
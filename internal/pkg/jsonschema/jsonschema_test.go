@@ -0,0 +1,124 @@
+package jsonschema
+
+import (
+	"errors"
+	"testing"
+)
+
+type testVote struct {
+	Name       string  `json:"name" jsonschema:"description=Legislator name"`
+	Option     string  `json:"option" jsonschema:"enum=si|no"`
+	Confidence float64 `json:"confidence,omitempty" jsonschema:"minimum=0,maximum=1"`
+}
+
+type testOutput struct {
+	Date    string      `json:"date"`
+	Total   int         `json:"total"`
+	Similar bool        `json:"similar,omitempty"`
+	Votes   []*testVote `json:"votes"`
+	Ignored string      `json:"ignored,omitempty" jsonschema:"-"`
+}
+
+func TestReflect(t *testing.T) {
+	s := Reflect(&testOutput{})
+	if s.Type != "object" {
+		t.Fatalf("expected an object, got %q", s.Type)
+	}
+	if _, ok := s.Properties["ignored"]; ok {
+		t.Error("fields tagged with jsonschema:\"-\" must be skipped")
+	}
+	required := map[string]bool{}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for name, want := range map[string]bool{"date": true, "total": true, "votes": true, "similar": false} {
+		if required[name] != want {
+			t.Errorf("required[%s] = %v, want %v", name, required[name], want)
+		}
+	}
+	vote := s.Properties["votes"].Items
+	if vote == nil || vote.Type != "object" {
+		t.Fatalf("expected the votes items to be objects, got %+v", vote)
+	}
+	if got := vote.Properties["option"].Enum; len(got) != 2 || got[0] != "si" || got[1] != "no" {
+		t.Errorf("unexpected enum %v", got)
+	}
+	if confidence := vote.Properties["confidence"]; confidence.Minimum == nil || *confidence.Minimum != 0 || confidence.Maximum == nil || *confidence.Maximum != 1 {
+		t.Errorf("unexpected confidence bounds %+v", confidence)
+	}
+	if got := vote.Properties["name"].Description; got != "Legislator name" {
+		t.Errorf("unexpected description %q", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Reflect(&testOutput{})
+	tests := []struct {
+		name string
+		json string
+		// path is the expected error path, empty when the document is valid:
+		path string
+	}{
+		{"valid", `{"date": "2023-07-20", "total": 2, "votes": [{"name": "A", "option": "si", "confidence": 0.5}, {"name": "B", "option": "no"}]}`, ""},
+		{"empty votes", `{"date": "2023-07-20", "total": 0, "votes": []}`, ""},
+		{"invalid JSON", `{"date": `, "$"},
+		{"not an object", `[]`, "$"},
+		{"missing required", `{"date": "2023-07-20", "votes": []}`, "$"},
+		{"unexpected property", `{"date": "2023-07-20", "total": 1, "votes": [], "extra": 1}`, "$"},
+		{"wrong string type", `{"date": 20230720, "total": 1, "votes": []}`, "$.date"},
+		{"float as integer", `{"date": "2023-07-20", "total": 1.5, "votes": []}`, "$.total"},
+		{"wrong boolean type", `{"date": "2023-07-20", "total": 1, "similar": "yes", "votes": []}`, "$.similar"},
+		{"not an array", `{"date": "2023-07-20", "total": 1, "votes": {}}`, "$.votes"},
+		{"enum", `{"date": "2023-07-20", "total": 1, "votes": [{"name": "A", "option": "tal vez"}]}`, "$.votes[0].option"},
+		{"below minimum", `{"date": "2023-07-20", "total": 1, "votes": [{"name": "A", "option": "si", "confidence": -0.1}]}`, "$.votes[0].confidence"},
+		{"above maximum", `{"date": "2023-07-20", "total": 1, "votes": [{"name": "A", "option": "no"}, {"name": "B", "option": "si", "confidence": 1.5}]}`, "$.votes[1].confidence"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate([]byte(tt.json))
+			if tt.path == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if validationErr.Path != tt.path {
+				t.Errorf("error path = %q, want %q (%v)", validationErr.Path, tt.path, err)
+			}
+		})
+	}
+}
+
+func TestStrict(t *testing.T) {
+	s := Reflect(&testOutput{})
+	strict, err := s.Strict()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Every property is required in strict mode, nested objects included:
+	for _, object := range []*Schema{strict, strict.Properties["votes"].Items} {
+		if len(object.Required) != len(object.Properties) {
+			t.Errorf("required = %v, want every property", object.Required)
+		}
+		if object.AdditionalProperties == nil || *object.AdditionalProperties {
+			t.Error("additional properties must be disallowed")
+		}
+	}
+	// The original schema is unchanged:
+	for _, name := range s.Required {
+		if name == "similar" {
+			t.Error("the strict copy modified the original schema")
+		}
+	}
+	if _, err := Reflect(&struct {
+		Votes []struct {
+			Totals map[string]int `json:"totals"`
+		} `json:"votes"`
+	}{}).Strict(); !errors.Is(err, ErrNotStrict) {
+		t.Errorf("error = %v, want %v", err, ErrNotStrict)
+	}
+}
@@ -0,0 +1,122 @@
+package ocr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultCommand is the tesseract binary name:
+	defaultCommand = "tesseract"
+	// defaultLanguage is the tesseract language used for recognition:
+	defaultLanguage = "spa"
+)
+
+var (
+	errInvalidTSV = errors.New("invalid tesseract TSV output")
+)
+
+// Word is a word recognized by tesseract, positions are in pixels:
+type Word struct {
+	Text       string  `json:"text"`
+	Left       int     `json:"left"`
+	Top        int     `json:"top"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Confidence float64 `json:"confidence"`
+	// Block, Paragraph and Line identify the line the word belongs to:
+	Block     int `json:"block"`
+	Paragraph int `json:"paragraph"`
+	Line      int `json:"line"`
+}
+
+// CenterX returns the horizontal center of the word:
+func (w Word) CenterX() int {
+	return w.Left + w.Width/2
+}
+
+// CenterY returns the vertical center of the word:
+func (w Word) CenterY() int {
+	return w.Top + w.Height/2
+}
+
+// Engine runs tesseract on images:
+type Engine struct {
+	command  string
+	language string
+}
+
+// Recognize runs tesseract on an image and returns the recognized words:
+func (e *Engine) Recognize(imagePath string) ([]Word, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(e.command, imagePath, "stdout", "-l", e.language, "tsv")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseTSV(&stdout)
+}
+
+// parseTSV parses the tesseract TSV output, only word level rows are kept:
+func parseTSV(r io.Reader) ([]Word, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	words := make([]Word, 0)
+	for i, record := range records {
+		// Skip the header:
+		if i == 0 {
+			continue
+		}
+		if len(record) < 12 {
+			return nil, errInvalidTSV
+		}
+		// Level 5 rows are words:
+		if record[0] != "5" || strings.TrimSpace(record[11]) == "" {
+			continue
+		}
+		values := make([]int, 10)
+		for j := range values {
+			values[j], _ = strconv.Atoi(record[j])
+		}
+		confidence, _ := strconv.ParseFloat(record[10], 64)
+		words = append(words, Word{
+			Text:       strings.TrimSpace(record[11]),
+			Block:      values[2],
+			Paragraph:  values[3],
+			Line:       values[4],
+			Left:       values[6],
+			Top:        values[7],
+			Width:      values[8],
+			Height:     values[9],
+			Confidence: confidence / 100,
+		})
+	}
+	return words, nil
+}
+
+// New initializes an engine, empty values fall back to the defaults:
+func New(command, language string) *Engine {
+	if command == "" {
+		command = defaultCommand
+	}
+	if language == "" {
+		language = defaultLanguage
+	}
+	return &Engine{
+		command:  command,
+		language: language,
+	}
+}
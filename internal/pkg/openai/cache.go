@@ -0,0 +1,114 @@
+package openai
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cache is a very basic on-disk cache for completion responses
+// Every entry is stored as a JSON file named after the request hash:
+type cache struct {
+	path string
+	ttl  time.Duration
+}
+
+// cacheEntry is the cached data for a single completion
+// The prompt and image hashes are kept so the cache doubles as an audit trail:
+type cacheEntry struct {
+	Key         string              `json:"key"`
+	CreatedAt   time.Time           `json:"created_at"`
+	Model       string              `json:"model"`
+	Prompt      string              `json:"prompt"`
+	ImageHashes []string            `json:"image_hashes"`
+	Response    *CompletionResponse `json:"response"`
+}
+
+// cacheKey hashes the full request, this covers the model, prompt,
+// image bytes and any other parameter like max tokens or response format:
+func cacheKey(reqJSON []byte) string {
+	sum := sha256.Sum256(reqJSON)
+	return hex.EncodeToString(sum[:])
+}
+
+// entryPath returns the file path for a given key:
+func (c *cache) entryPath(key string) string {
+	return filepath.Join(c.path, key+".json")
+}
+
+// get retrieves a cached response, expired entries are removed:
+func (c *cache) get(key string) (*CompletionResponse, bool) {
+	rawEntry, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(rawEntry, &entry); err != nil {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		os.Remove(c.entryPath(key))
+		return nil, false
+	}
+	if entry.Response == nil {
+		return nil, false
+	}
+	return entry.Response, true
+}
+
+// put stores a response for the given request:
+func (c *cache) put(key string, req *CompletionRequest, res *CompletionResponse) error {
+	if c.path == "" {
+		return errors.New("no cache path set")
+	}
+	if err := os.MkdirAll(c.path, 0755); err != nil {
+		return err
+	}
+	prompt, imageHashes := summarizeRequest(req)
+	entry := cacheEntry{
+		Key:         key,
+		CreatedAt:   time.Now(),
+		Model:       req.Model,
+		Prompt:      prompt,
+		ImageHashes: imageHashes,
+		Response:    res,
+	}
+	rawEntry, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.entryPath(key), rawEntry, 0644)
+}
+
+// summarizeRequest returns the text parts of a request and the hashes of
+// the images, the images themselves aren't stored as they're already on disk:
+func summarizeRequest(req *CompletionRequest) (string, []string) {
+	var prompt strings.Builder
+	imageHashes := make([]string, 0)
+	for _, message := range req.Messages {
+		for _, item := range message.Content {
+			if item.Text != "" {
+				prompt.WriteString(message.Role + ": " + strings.TrimSpace(item.Text) + "\n")
+			}
+			if item.ImageURL == nil {
+				continue
+			}
+			imageData := item.ImageURL.URL
+			if i := strings.Index(imageData, ","); i >= 0 {
+				if decoded, err := base64.StdEncoding.DecodeString(imageData[i+1:]); err == nil {
+					sum := sha256.Sum256(decoded)
+					imageHashes = append(imageHashes, hex.EncodeToString(sum[:]))
+					continue
+				}
+			}
+			imageHashes = append(imageHashes, imageData)
+		}
+	}
+	return prompt.String(), imageHashes
+}
@@ -4,6 +4,6 @@ const (
 	// completionEndpoint is the endpoint described in: https://platform.openai.com/docs/guides/text-generation/chat-completions-api
 	completionEndPoint = "https://api.openai.com/v1/chat/completions"
 
-	// defaultModel sets the default OpenAI model to use:
-	defaultModel = "gpt-4-vision-preview"
+	// DefaultModel is the OpenAI model used when a request doesn't set one:
+	DefaultModel = "gpt-4-vision-preview"
 )
@@ -17,17 +17,14 @@ var (
 
 // OAIClient wraps OpenAI API calls:
 type OAIClient struct {
-	cfg *config.Config
+	cfg   *config.Config
+	cache *cache
 }
 
 // Completion calls the chat completion endpoint: https://platform.openai.com/docs/guides/text-generation/chat-completions-api
 func (c *OAIClient) Completion(completionRequest *CompletionRequest) (*CompletionResponse, error) {
-	if c.cfg.OpenAIConfig.Token == "" {
-		return nil, errNoTokenSet
-	}
-
 	if completionRequest.Model == "" {
-		completionRequest.Model = defaultModel
+		completionRequest.Model = DefaultModel
 	}
 
 	reqJSON, err := completionRequest.ToJSON()
@@ -35,6 +32,19 @@ func (c *OAIClient) Completion(completionRequest *CompletionRequest) (*Completio
 		return nil, err
 	}
 
+	// Identical requests are served from the cache unless it's disabled,
+	// the token is only needed when the request isn't cached:
+	key := cacheKey(reqJSON)
+	if !c.cfg.OpenAIConfig.DisableCache {
+		if cachedResponse, ok := c.cache.get(key); ok && c.valid(completionRequest, cachedResponse) {
+			cachedResponse.Cached = true
+			return cachedResponse, nil
+		}
+	}
+	if c.cfg.OpenAIConfig.Token == "" {
+		return nil, errNoTokenSet
+	}
+
 	req, err := http.NewRequest(
 		http.MethodPost,
 		completionEndPoint,
@@ -68,12 +78,32 @@ func (c *OAIClient) Completion(completionRequest *CompletionRequest) (*Completio
 	if err := completionResponse.FromJSON(rawBody); err != nil {
 		return nil, err
 	}
+
+	// Only cache valid responses, a cache write failure
+	// shouldn't discard a response we already paid for:
+	if c.valid(completionRequest, &completionResponse) {
+		c.cache.put(key, completionRequest, &completionResponse)
+	}
 	return &completionResponse, nil
 }
 
+// valid reports if a response has choices and its reply passes the request validation:
+func (c *OAIClient) valid(req *CompletionRequest, res *CompletionResponse) bool {
+	if len(res.Choices) == 0 {
+		return false
+	}
+	return req.Validate == nil || req.Validate(res.Choices[0].Message.Content) == nil
+}
+
 // New initializes a new OpenAI API client:
 func New(cfg *config.Config) *OAIClient {
+	// The TTL is validated during app initialization:
+	ttl, _ := cfg.OpenAIConfig.GetCacheTTL()
 	return &OAIClient{
 		cfg: cfg,
+		cache: &cache{
+			path: cfg.OpenAIConfig.CachePath,
+			ttl:  ttl,
+		},
 	}
 }
@@ -0,0 +1,126 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/jsonschema"
+)
+
+// Response format modes for structured completions:
+const (
+	// ResponseFormatNone relies on the prompt only, required by models without JSON mode:
+	ResponseFormatNone = ""
+	// ResponseFormatJSONObject enables the JSON mode:
+	ResponseFormatJSONObject = "json_object"
+	// ResponseFormatJSONSchema enables structured outputs using the derived schema:
+	ResponseFormatJSONSchema = "json_schema"
+
+	// defaultMaxAttempts is the amount of times a structured completion is attempted:
+	defaultMaxAttempts = 3
+)
+
+var (
+	errNoChoices = errors.New("no choices returned from completion API")
+)
+
+// Completer is implemented by anything that runs completions,
+// this allows callers to wrap the client -e.g. for usage tracking-:
+type Completer interface {
+	Completion(*CompletionRequest) (*CompletionResponse, error)
+}
+
+// StructuredOptions sets options for structured completions:
+type StructuredOptions struct {
+	// Name identifies the schema when using structured outputs:
+	Name string
+	// ResponseFormat is one of the ResponseFormat constants:
+	ResponseFormat string
+	// MaxAttempts is the amount of attempts before giving up:
+	MaxAttempts int
+}
+
+// StructuredCompletion runs a completion whose output must match the JSON Schema derived from out
+// The schema is appended to the last message and the reply is validated, when validation fails
+// the model is asked again including the validation error. The valid reply is decoded into out:
+func StructuredCompletion(c Completer, req *CompletionRequest, out any, opts StructuredOptions) (*CompletionResponse, error) {
+	if len(req.Messages) == 0 {
+		return nil, errors.New("no messages in request")
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.Name == "" {
+		opts.Name = "output"
+	}
+	schema := jsonschema.Reflect(out)
+	switch opts.ResponseFormat {
+	case ResponseFormatJSONObject:
+		req.ResponseFormat = &CompletionResponseFormatJSON
+	case ResponseFormatJSONSchema:
+		// Optional fields are required in strict mode, the reply is still validated with the original schema:
+		strict, err := schema.Strict()
+		if err != nil {
+			return nil, err
+		}
+		req.ResponseFormat = &CompletionResponseFormat{
+			Type: ResponseFormatJSONSchema,
+			JSONSchema: &CompletionJSONSchema{
+				Name:   opts.Name,
+				Strict: true,
+				Schema: strict,
+			},
+		}
+	}
+	// Replies that don't match the schema aren't cached, so they aren't replayed on the next run:
+	req.Validate = func(reply string) error {
+		return schema.Validate([]byte(ExtractJSON(reply)))
+	}
+	last := &req.Messages[len(req.Messages)-1]
+	last.Content = append(last.Content, ContentItem{
+		Type: "text",
+		Text: "Reply only with a JSON object matching this JSON Schema:\n" + schema.String(),
+	})
+
+	var lastErr error
+	for attempt := 1; attempt <= opts.MaxAttempts; attempt++ {
+		res, err := c.Completion(req)
+		if err != nil {
+			return nil, err
+		}
+		if len(res.Choices) == 0 {
+			return nil, errNoChoices
+		}
+		reply := res.Choices[0].Message.Content
+		jsonBlock := ExtractJSON(reply)
+		if err := schema.Validate([]byte(jsonBlock)); err != nil {
+			lastErr = err
+			// Re-ask including the invalid reply and the validation error:
+			req.Messages = append(req.Messages,
+				Message{Role: "assistant", Content: []ContentItem{{Type: "text", Text: reply}}},
+				Message{Role: "user", Content: []ContentItem{{
+					Type: "text",
+					Text: fmt.Sprintf("The previous reply doesn't match the schema (%s). Reply again with only the corrected JSON object.", err),
+				}}},
+			)
+			continue
+		}
+		if err := json.Unmarshal([]byte(jsonBlock), out); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("no valid output after %d attempts: %w", opts.MaxAttempts, lastErr)
+}
+
+// ExtractJSON returns the JSON block of a reply, handling markdown code blocks:
+func ExtractJSON(reply string) string {
+	if _, after, found := strings.Cut(reply, "```json"); found {
+		reply, _, _ = strings.Cut(after, "```")
+	} else if _, after, found := strings.Cut(reply, "```"); found {
+		reply, _, _ = strings.Cut(after, "```")
+	}
+	return strings.TrimSpace(reply)
+}
@@ -1,16 +1,29 @@
 package openai
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/jsonschema"
+)
 
 type CompletionRequest struct {
 	Model          string                    `json:"model"`
 	Messages       []Message                 `json:"messages"`
 	MaxTokens      int                       `json:"max_tokens"`
 	ResponseFormat *CompletionResponseFormat `json:"response_format,omitempty"`
+	// Validate optionally checks the reply, only valid replies are cached:
+	Validate func(reply string) error `json:"-"`
 }
 
 type CompletionResponseFormat struct {
-	Type string `json:"type"`
+	Type       string                `json:"type"`
+	JSONSchema *CompletionJSONSchema `json:"json_schema,omitempty"`
+}
+
+type CompletionJSONSchema struct {
+	Name   string             `json:"name"`
+	Strict bool               `json:"strict"`
+	Schema *jsonschema.Schema `json:"schema"`
 }
 
 var CompletionResponseFormatJSON = CompletionResponseFormat{
@@ -43,6 +56,15 @@ type ImageURL struct {
 type CompletionResponse struct {
 	ID      string                     `json:"id"`
 	Choices []CompletionResponseChoice `json:"choices"`
+	Usage   Usage                      `json:"usage"`
+	// Cached is set when the response was served from the local cache:
+	Cached bool `json:"-"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 func (c *CompletionResponse) FromJSON(rawJSON []byte) error {
@@ -0,0 +1,250 @@
+package parquet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Type is a column type:
+type Type int
+
+// Supported column types, every column is required -non nullable-:
+const (
+	Boolean Type = iota
+	Int64
+	Double
+	String
+)
+
+// Parquet physical and converted types, see parquet.thrift:
+const (
+	physicalBoolean   = 0
+	physicalInt64     = 2
+	physicalDouble    = 5
+	physicalByteArray = 6
+
+	convertedUTF8 = 0
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+
+	pageTypeData = 0
+)
+
+// magic starts and ends every Parquet file:
+const magic = "PAR1"
+
+// createdBy is written to the file metadata:
+const createdBy = "congreso-votaciones"
+
+var errValueType = errors.New("value doesn't match the column type")
+
+// Column is a column definition:
+type Column struct {
+	Name string
+	Type Type
+}
+
+// physicalType returns the Parquet type of a column:
+func (c *Column) physicalType() int32 {
+	switch c.Type {
+	case Boolean:
+		return physicalBoolean
+	case Int64:
+		return physicalInt64
+	case Double:
+		return physicalDouble
+	default:
+		return physicalByteArray
+	}
+}
+
+// Write writes a Parquet file with a single row group and a single uncompressed, PLAIN encoded
+// page per column. Values must be bool, int, int64, float64 or string according to the column type:
+func Write(w io.Writer, columns []*Column, rows [][]interface{}) error {
+	out := &countingWriter{w: bufio.NewWriter(w)}
+	out.Write([]byte(magic))
+	chunks := make([]*columnChunk, 0, len(columns))
+	for i, column := range columns {
+		data, err := encodeColumn(column, rows, i)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		header := &compactWriter{}
+		writePageHeader(header, len(rows), len(data))
+		chunk := &columnChunk{
+			column: column,
+			offset: out.n,
+			size:   int64(len(header.buf) + len(data)),
+		}
+		out.Write(header.buf)
+		out.Write(data)
+		chunks = append(chunks, chunk)
+	}
+	metadata := &compactWriter{}
+	writeFileMetaData(metadata, columns, chunks, len(rows))
+	out.Write(metadata.buf)
+	var length [4]byte
+	binary.LittleEndian.PutUint32(length[:], uint32(len(metadata.buf)))
+	out.Write(length[:])
+	out.Write([]byte(magic))
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.(*bufio.Writer).Flush()
+}
+
+// columnChunk records where a column was written:
+type columnChunk struct {
+	column *Column
+	offset int64
+	size   int64
+}
+
+// encodeColumn returns the PLAIN encoded values of a column:
+func encodeColumn(column *Column, rows [][]interface{}, index int) ([]byte, error) {
+	data := make([]byte, 0)
+	if column.Type == Boolean {
+		data = make([]byte, (len(rows)+7)/8)
+	}
+	for i, row := range rows {
+		if index >= len(row) {
+			return nil, fmt.Errorf("row %d: missing value", i)
+		}
+		switch column.Type {
+		case Boolean:
+			v, ok := row[index].(bool)
+			if !ok {
+				return nil, fmt.Errorf("row %d: %w", i, errValueType)
+			}
+			if v {
+				data[i/8] |= 1 << (i % 8)
+			}
+		case Int64:
+			var v int64
+			switch value := row[index].(type) {
+			case int:
+				v = int64(value)
+			case int64:
+				v = value
+			default:
+				return nil, fmt.Errorf("row %d: %w", i, errValueType)
+			}
+			data = binary.LittleEndian.AppendUint64(data, uint64(v))
+		case Double:
+			v, ok := row[index].(float64)
+			if !ok {
+				return nil, fmt.Errorf("row %d: %w", i, errValueType)
+			}
+			data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+		case String:
+			v, ok := row[index].(string)
+			if !ok {
+				return nil, fmt.Errorf("row %d: %w", i, errValueType)
+			}
+			data = binary.LittleEndian.AppendUint32(data, uint32(len(v)))
+			data = append(data, v...)
+		}
+	}
+	return data, nil
+}
+
+// writePageHeader writes a PageHeader with a DataPageHeader:
+func writePageHeader(c *compactWriter, values, size int) {
+	c.structBegin()
+	c.i32Field(1, pageTypeData)
+	c.i32Field(2, int32(size))
+	c.i32Field(3, int32(size))
+	c.fieldHeader(5, compactStruct)
+	c.structBegin()
+	c.i32Field(1, int32(values))
+	c.i32Field(2, encodingPlain)
+	c.i32Field(3, encodingRLE)
+	c.i32Field(4, encodingRLE)
+	c.structEnd()
+	c.structEnd()
+}
+
+// writeFileMetaData writes the FileMetaData: the schema and a single row group:
+func writeFileMetaData(c *compactWriter, columns []*Column, chunks []*columnChunk, rows int) {
+	c.structBegin()
+	c.i32Field(1, 1)
+	// Schema, the root element is followed by the columns:
+	c.fieldHeader(2, compactList)
+	c.listHeader(len(columns)+1, compactStruct)
+	c.structBegin()
+	c.binaryField(4, "schema")
+	c.i32Field(5, int32(len(columns)))
+	c.structEnd()
+	for _, column := range columns {
+		c.structBegin()
+		c.i32Field(1, column.physicalType())
+		c.i32Field(3, repetitionRequired)
+		c.binaryField(4, column.Name)
+		if column.Type == String {
+			c.i32Field(6, convertedUTF8)
+		}
+		c.structEnd()
+	}
+	c.i64Field(3, int64(rows))
+	// Row groups:
+	c.fieldHeader(4, compactList)
+	c.listHeader(1, compactStruct)
+	c.structBegin()
+	c.fieldHeader(1, compactList)
+	c.listHeader(len(chunks), compactStruct)
+	var totalSize int64
+	for _, chunk := range chunks {
+		totalSize += chunk.size
+		c.structBegin()
+		c.i64Field(2, chunk.offset)
+		c.fieldHeader(3, compactStruct)
+		c.structBegin()
+		c.i32Field(1, chunk.column.physicalType())
+		c.fieldHeader(2, compactList)
+		c.listHeader(2, compactI32)
+		c.varint(zigzag(encodingPlain))
+		c.varint(zigzag(encodingRLE))
+		c.fieldHeader(3, compactList)
+		c.listHeader(1, compactBinary)
+		c.binary(chunk.column.Name)
+		c.i32Field(4, codecUncompressed)
+		c.i64Field(5, int64(rows))
+		c.i64Field(6, chunk.size)
+		c.i64Field(7, chunk.size)
+		c.i64Field(9, chunk.offset)
+		c.structEnd()
+		c.structEnd()
+	}
+	c.i64Field(2, totalSize)
+	c.i64Field(3, int64(rows))
+	c.structEnd()
+	c.binaryField(6, createdBy)
+	c.structEnd()
+}
+
+// countingWriter tracks the offset and keeps the first error:
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// Write implements io.Writer:
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,243 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// compactReader decodes Thrift compact structs into maps of field ID to value, independently
+// of compactWriter. Integers are returned as int64, binaries as strings, lists as []interface{}
+// and structs as map[int16]interface{}:
+type compactReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *compactReader) byte() byte {
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) varint() uint64 {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		b := r.byte()
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v
+		}
+	}
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) value(valueType byte) interface{} {
+	switch valueType {
+	case 1:
+		return true
+	case 2:
+		return false
+	case compactI32, compactI64:
+		return r.zigzag()
+	case compactBinary:
+		n := int(r.varint())
+		v := string(r.buf[r.pos : r.pos+n])
+		r.pos += n
+		return v
+	case compactList:
+		header := r.byte()
+		size, elementType := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size; i++ {
+			list = append(list, r.value(elementType))
+		}
+		return list
+	case compactStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unsupported compact type %d", valueType))
+}
+
+func (r *compactReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	columns := []*Column{
+		{Name: "name", Type: String},
+		{Name: "votes", Type: Int64},
+		{Name: "confidence", Type: Double},
+		{Name: "registered", Type: Boolean},
+	}
+	// More than 8 rows so the booleans take more than a byte:
+	rows := [][]interface{}{
+		{"Juan Pérez", 1, 0.5, true},
+		{"", int64(-3), -1.25, false},
+		{"Ñandutí", 0, 0.0, true},
+		{"a", math.MaxInt64, math.Inf(1), true},
+		{"b", math.MinInt64, 1e-9, false},
+		{"c", 5, 2.0, false},
+		{"d", 6, 3.0, false},
+		{"e", 7, 4.0, false},
+		{"f", 8, 5.0, true},
+		{"g", 9, 6.0, false},
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, columns, rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		t.Fatal("missing magic")
+	}
+	metadataSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadataStart := len(data) - 8 - metadataSize
+	reader := &compactReader{buf: data[metadataStart : len(data)-8]}
+	metadata := reader.readStruct()
+	if reader.pos != metadataSize {
+		t.Errorf("decoded %d metadata bytes, the footer says %d", reader.pos, metadataSize)
+	}
+	if metadata[3] != int64(len(rows)) {
+		t.Errorf("num_rows = %v, want %d", metadata[3], len(rows))
+	}
+	schema := metadata[2].([]interface{})
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("expected %d schema elements, got %d", len(columns)+1, len(schema))
+	}
+	if root := schema[0].(map[int16]interface{}); root[5] != int64(len(columns)) {
+		t.Errorf("root num_children = %v, want %d", root[5], len(columns))
+	}
+	rowGroups := metadata[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("expected a row group, got %d", len(rowGroups))
+	}
+	rowGroup := rowGroups[0].(map[int16]interface{})
+	if rowGroup[3] != int64(len(rows)) {
+		t.Errorf("row group num_rows = %v, want %d", rowGroup[3], len(rows))
+	}
+	chunks := rowGroup[1].([]interface{})
+	for i, column := range columns {
+		element := schema[i+1].(map[int16]interface{})
+		if element[4] != column.Name || element[1] != int64(column.physicalType()) || element[3] != int64(repetitionRequired) {
+			t.Errorf("unexpected schema element %v for column %s", element, column.Name)
+		}
+		if _, utf8 := element[6]; utf8 != (column.Type == String) {
+			t.Errorf("column %s: converted type %v", column.Name, element[6])
+		}
+		meta := chunks[i].(map[int16]interface{})[3].(map[int16]interface{})
+		if path := meta[3].([]interface{}); len(path) != 1 || path[0] != column.Name {
+			t.Errorf("column %s: path %v", column.Name, path)
+		}
+		if meta[4] != int64(codecUncompressed) || meta[5] != int64(len(rows)) {
+			t.Errorf("column %s: codec %v, num_values %v", column.Name, meta[4], meta[5])
+		}
+		offset := int(meta[9].(int64))
+		page := &compactReader{buf: data[:metadataStart], pos: offset}
+		header := page.readStruct()
+		size := int(header[3].(int64))
+		if header[1] != int64(pageTypeData) || header[2] != header[3] {
+			t.Errorf("column %s: unexpected page header %v", column.Name, header)
+		}
+		if dataPage := header[5].(map[int16]interface{}); dataPage[1] != int64(len(rows)) || dataPage[2] != int64(encodingPlain) {
+			t.Errorf("column %s: unexpected data page header %v", column.Name, dataPage)
+		}
+		if chunkSize := meta[6].(int64); int(chunkSize) != page.pos-offset+size {
+			t.Errorf("column %s: chunk size %d, the page takes %d bytes", column.Name, chunkSize, page.pos-offset+size)
+		}
+		values := decodePlain(t, column.Type, data[page.pos:page.pos+size], len(rows))
+		for j, row := range rows {
+			want := row[i]
+			if n, ok := want.(int); ok {
+				want = int64(n)
+			}
+			if values[j] != want {
+				t.Errorf("column %s row %d: got %v, want %v", column.Name, j, values[j], want)
+			}
+		}
+	}
+}
+
+// decodePlain decodes PLAIN encoded values:
+func decodePlain(t *testing.T, columnType Type, data []byte, count int) []interface{} {
+	t.Helper()
+	values := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		switch columnType {
+		case Boolean:
+			values = append(values, data[i/8]&(1<<(i%8)) != 0)
+		case Int64:
+			values = append(values, int64(binary.LittleEndian.Uint64(data[i*8:])))
+		case Double:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[i*8:])))
+		case String:
+			n := int(binary.LittleEndian.Uint32(data))
+			values = append(values, string(data[4:4+n]))
+			data = data[4+n:]
+		}
+	}
+	return values
+}
+
+func TestWriteEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []*Column{{Name: "name", Type: String}}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data := buf.Bytes()
+	if string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		t.Fatal("missing magic")
+	}
+	metadataSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metadata := (&compactReader{buf: data[len(data)-8-metadataSize : len(data)-8]}).readStruct()
+	if metadata[3] != int64(0) {
+		t.Errorf("num_rows = %v, want 0", metadata[3])
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	columns := []*Column{{Name: "name", Type: String}, {Name: "votes", Type: Int64}}
+	tests := []struct {
+		name string
+		rows [][]interface{}
+		err  error
+	}{
+		{"wrong type", [][]interface{}{{"Juan", "1"}}, errValueType},
+		{"float as integer", [][]interface{}{{"Juan", 1.0}}, errValueType},
+		{"missing value", [][]interface{}{{"Juan"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Write(&bytes.Buffer{}, columns, tt.rows)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package parquet
+
+// Thrift compact protocol types used by the Parquet metadata:
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compactWriter encodes Thrift structs using the compact protocol:
+type compactWriter struct {
+	buf []byte
+	// lastField is the last field ID of the current struct, fields are delta encoded:
+	lastField int16
+	// fieldStack holds the last field IDs of the enclosing structs:
+	fieldStack []int16
+}
+
+// structBegin starts a struct, nested structs restart the field deltas:
+func (c *compactWriter) structBegin() {
+	c.fieldStack = append(c.fieldStack, c.lastField)
+	c.lastField = 0
+}
+
+// structEnd writes the stop field and restores the enclosing struct:
+func (c *compactWriter) structEnd() {
+	c.buf = append(c.buf, 0)
+	c.lastField = c.fieldStack[len(c.fieldStack)-1]
+	c.fieldStack = c.fieldStack[:len(c.fieldStack)-1]
+}
+
+// fieldHeader writes a field header, short form when the delta fits in 4 bits:
+func (c *compactWriter) fieldHeader(id int16, fieldType byte) {
+	if delta := id - c.lastField; delta > 0 && delta <= 15 {
+		c.buf = append(c.buf, byte(delta)<<4|fieldType)
+	} else {
+		c.buf = append(c.buf, fieldType)
+		c.varint(zigzag(int64(id)))
+	}
+	c.lastField = id
+}
+
+// listHeader writes a list header:
+func (c *compactWriter) listHeader(size int, elementType byte) {
+	if size < 15 {
+		c.buf = append(c.buf, byte(size)<<4|elementType)
+		return
+	}
+	c.buf = append(c.buf, 0xf0|elementType)
+	c.varint(uint64(size))
+}
+
+func (c *compactWriter) i32Field(id int16, v int32) {
+	c.fieldHeader(id, compactI32)
+	c.varint(zigzag(int64(v)))
+}
+
+func (c *compactWriter) i64Field(id int16, v int64) {
+	c.fieldHeader(id, compactI64)
+	c.varint(zigzag(v))
+}
+
+func (c *compactWriter) binaryField(id int16, v string) {
+	c.fieldHeader(id, compactBinary)
+	c.binary(v)
+}
+
+// binary writes a length prefixed string:
+func (c *compactWriter) binary(v string) {
+	c.varint(uint64(len(v)))
+	c.buf = append(c.buf, v...)
+}
+
+// varint writes an unsigned LEB128 integer:
+func (c *compactWriter) varint(v uint64) {
+	for v >= 0x80 {
+		c.buf = append(c.buf, byte(v)|0x80)
+		v >>= 7
+	}
+	c.buf = append(c.buf, byte(v))
+}
+
+// zigzag maps signed integers to unsigned so small negative values stay small:
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
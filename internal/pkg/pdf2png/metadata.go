@@ -0,0 +1,85 @@
+package pdf2png
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/pdfmeta"
+)
+
+// GetMetadata reads the information dictionary, page sizes and text layer of a PDF file:
+func GetMetadata(filePath string) (*pdfmeta.Metadata, error) {
+	pdfBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{
+		File: &pdfBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
+		Document: doc.Document,
+	})
+
+	sum := sha256.Sum256(pdfBytes)
+	metadata := &pdfmeta.Metadata{
+		FileSize: int64(len(pdfBytes)),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Info:     make(map[string]string),
+		XMP:      parseXMP(pdfBytes),
+	}
+	info, err := instance.GetMetaData(&requests.GetMetaData{
+		Document: doc.Document,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range info.Tags {
+		if value := strings.TrimSpace(tag.Value); value != "" {
+			metadata.Info[tag.Tag] = value
+		}
+	}
+
+	pageCount, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
+		Document: doc.Document,
+	})
+	if err != nil {
+		return nil, err
+	}
+	metadata.PageCount = pageCount.PageCount
+	for i := 0; i < pageCount.PageCount; i++ {
+		size, err := instance.FPDF_GetPageSizeByIndex(&requests.FPDF_GetPageSizeByIndex{
+			Document: doc.Document,
+			Index:    i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		pageText, err := instance.GetPageText(&requests.GetPageText{
+			Page: requests.Page{
+				ByIndex: &requests.PageByIndex{
+					Document: doc.Document,
+					Index:    i,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		textChars := len(strings.Join(strings.Fields(pageText.Text), ""))
+		metadata.Pages = append(metadata.Pages, pdfmeta.PageInfo{
+			Width:     size.Width,
+			Height:    size.Height,
+			TextChars: textChars,
+			HasText:   textChars > 0,
+		})
+		metadata.Texts = append(metadata.Texts, pageText.Text)
+	}
+	return metadata, nil
+}
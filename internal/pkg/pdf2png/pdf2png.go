@@ -108,3 +108,46 @@ func GetPageCount(filePath string) (int, error) {
 
 	return pageCount.PageCount, nil
 }
+
+// GetPageTexts returns the text layer of every page in a PDF file
+// Scanned pages without a text layer return an empty string:
+func GetPageTexts(filePath string) ([]string, error) {
+	pdfBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{
+		File: &pdfBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
+		Document: doc.Document,
+	})
+
+	pageCount, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
+		Document: doc.Document,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]string, 0, pageCount.PageCount)
+	for i := 0; i < pageCount.PageCount; i++ {
+		pageText, err := instance.GetPageText(&requests.GetPageText{
+			Page: requests.Page{
+				ByIndex: &requests.PageByIndex{
+					Document: doc.Document,
+					Index:    i,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, pageText.Text)
+	}
+	return texts, nil
+}
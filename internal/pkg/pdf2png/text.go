@@ -0,0 +1,233 @@
+package pdf2png
+
+import (
+	"os"
+
+	"github.com/klippa-app/go-pdfium/enums"
+	"github.com/klippa-app/go-pdfium/requests"
+)
+
+// TextBox is a run of text from the PDF text layer
+// Positions are relative to the page size -0 to 1- with the origin at the top left corner:
+type TextBox struct {
+	Text   string  `json:"text"`
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Right  float64 `json:"right"`
+	Bottom float64 `json:"bottom"`
+}
+
+// GetPageTextBoxes returns the positioned text runs of every page in a PDF file
+// Scanned pages without a text layer return no boxes:
+func GetPageTextBoxes(filePath string) ([][]TextBox, error) {
+	pdfBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{
+		File: &pdfBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
+		Document: doc.Document,
+	})
+
+	pageCount, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
+		Document: doc.Document,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([][]TextBox, 0, pageCount.PageCount)
+	for i := 0; i < pageCount.PageCount; i++ {
+		size, err := instance.FPDF_GetPageSizeByIndex(&requests.FPDF_GetPageSizeByIndex{
+			Document: doc.Document,
+			Index:    i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		structured, err := instance.GetPageTextStructured(&requests.GetPageTextStructured{
+			Page: requests.Page{
+				ByIndex: &requests.PageByIndex{
+					Document: doc.Document,
+					Index:    i,
+				},
+			},
+			Mode: requests.GetPageTextStructuredModeRects,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// PDF coordinates start at the bottom left corner:
+		boxes := make([]TextBox, 0, len(structured.Rects))
+		for _, rect := range structured.Rects {
+			boxes = append(boxes, TextBox{
+				Text:   rect.Text,
+				Left:   rect.PointPosition.Left / size.Width,
+				Top:    (size.Height - rect.PointPosition.Top) / size.Height,
+				Right:  rect.PointPosition.Right / size.Width,
+				Bottom: (size.Height - rect.PointPosition.Bottom) / size.Height,
+			})
+		}
+		pages = append(pages, boxes)
+	}
+	return pages, nil
+}
+
+// PageLayout holds the characters and ruling lines of a page:
+type PageLayout struct {
+	// Width and Height are expressed in points:
+	Width  float64
+	Height float64
+	// Chars are the characters of the text layer, spaces included:
+	Chars []TextBox
+	// Rulings are the horizontal and vertical lines drawn on the page:
+	Rulings []Ruling
+}
+
+// Ruling is a horizontal or vertical line, positions are relative to the page size:
+type Ruling struct {
+	Horizontal bool
+	// Position is the vertical position of horizontal lines or the horizontal position of vertical lines:
+	Position float64
+	// Start and End are the limits of the line along its direction:
+	Start float64
+	End   float64
+}
+
+// rulingThickness is the maximum thickness in points of a path considered a line:
+const rulingThickness = 2
+
+// GetPageLayouts returns the character boxes and ruling lines of every page in a PDF file:
+func GetPageLayouts(filePath string) ([]*PageLayout, error) {
+	pdfBytes, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{
+		File: &pdfBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{
+		Document: doc.Document,
+	})
+
+	pageCount, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{
+		Document: doc.Document,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	layouts := make([]*PageLayout, 0, pageCount.PageCount)
+	for i := 0; i < pageCount.PageCount; i++ {
+		page := requests.Page{
+			ByIndex: &requests.PageByIndex{
+				Document: doc.Document,
+				Index:    i,
+			},
+		}
+		size, err := instance.FPDF_GetPageSizeByIndex(&requests.FPDF_GetPageSizeByIndex{
+			Document: doc.Document,
+			Index:    i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		structured, err := instance.GetPageTextStructured(&requests.GetPageTextStructured{
+			Page: page,
+			Mode: requests.GetPageTextStructuredModeChars,
+		})
+		if err != nil {
+			return nil, err
+		}
+		layout := &PageLayout{
+			Width:   size.Width,
+			Height:  size.Height,
+			Chars:   make([]TextBox, 0, len(structured.Chars)),
+			Rulings: make([]Ruling, 0),
+		}
+		for _, char := range structured.Chars {
+			if char.Text == "" {
+				continue
+			}
+			layout.Chars = append(layout.Chars, TextBox{
+				Text:   char.Text,
+				Left:   char.PointPosition.Left / size.Width,
+				Top:    (size.Height - char.PointPosition.Top) / size.Height,
+				Right:  char.PointPosition.Right / size.Width,
+				Bottom: (size.Height - char.PointPosition.Bottom) / size.Height,
+			})
+		}
+		rulings, err := getPageRulings(page, size.Width, size.Height)
+		if err != nil {
+			return nil, err
+		}
+		layout.Rulings = rulings
+		layouts = append(layouts, layout)
+	}
+	return layouts, nil
+}
+
+// getPageRulings returns the lines drawn by path objects, thin paths are lines
+// and other paths are treated as rectangles -cell borders-:
+func getPageRulings(page requests.Page, width, height float64) ([]Ruling, error) {
+	count, err := instance.FPDFPage_CountObjects(&requests.FPDFPage_CountObjects{
+		Page: page,
+	})
+	if err != nil {
+		return nil, err
+	}
+	rulings := make([]Ruling, 0)
+	for i := 0; i < count.Count; i++ {
+		object, err := instance.FPDFPage_GetObject(&requests.FPDFPage_GetObject{
+			Page:  page,
+			Index: i,
+		})
+		if err != nil {
+			return nil, err
+		}
+		objectType, err := instance.FPDFPageObj_GetType(&requests.FPDFPageObj_GetType{
+			PageObject: object.PageObject,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if objectType.Type != enums.FPDF_PAGEOBJ_PATH {
+			continue
+		}
+		bounds, err := instance.FPDFPageObj_GetBounds(&requests.FPDFPageObj_GetBounds{
+			PageObject: object.PageObject,
+		})
+		if err != nil {
+			return nil, err
+		}
+		left, right := float64(bounds.Left)/width, float64(bounds.Right)/width
+		top, bottom := (height-float64(bounds.Top))/height, (height-float64(bounds.Bottom))/height
+		horizontal := Ruling{Horizontal: true, Start: left, End: right}
+		vertical := Ruling{Start: top, End: bottom}
+		switch {
+		case float64(bounds.Top-bounds.Bottom) <= rulingThickness:
+			horizontal.Position = (top + bottom) / 2
+			rulings = append(rulings, horizontal)
+		case float64(bounds.Right-bounds.Left) <= rulingThickness:
+			vertical.Position = (left + right) / 2
+			rulings = append(rulings, vertical)
+		default:
+			topEdge, bottomEdge := horizontal, horizontal
+			topEdge.Position, bottomEdge.Position = top, bottom
+			leftEdge, rightEdge := vertical, vertical
+			leftEdge.Position, rightEdge.Position = left, right
+			rulings = append(rulings, topEdge, bottomEdge, leftEdge, rightEdge)
+		}
+	}
+	return rulings, nil
+}
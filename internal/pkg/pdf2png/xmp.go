@@ -0,0 +1,41 @@
+package pdf2png
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	xmpStart = []byte("<x:xmpmeta")
+	xmpEnd   = []byte("</x:xmpmeta>")
+
+	// xmpElementRe and xmpAttributeRe match simple properties in both of their XMP serializations:
+	xmpElementRe   = regexp.MustCompile(`<((?:xmp|pdf|dc|xmpMM):[A-Za-z]+)>([^<]+)</`)
+	xmpAttributeRe = regexp.MustCompile(`\s((?:xmp|pdf|dc|xmpMM):[A-Za-z]+)="([^"]*)"`)
+)
+
+// parseXMP extracts the simple properties -e.g. "xmp:CreateDate", "xmp:CreatorTool", "pdf:Producer"-
+// of the XMP metadata packet. Only uncompressed packets are supported, when the document was
+// updated incrementally the last packet is used:
+func parseXMP(pdfBytes []byte) map[string]string {
+	start := bytes.LastIndex(pdfBytes, xmpStart)
+	if start < 0 {
+		return nil
+	}
+	end := bytes.Index(pdfBytes[start:], xmpEnd)
+	if end < 0 {
+		return nil
+	}
+	packet := pdfBytes[start : start+end]
+	properties := make(map[string]string)
+	for _, re := range []*regexp.Regexp{xmpElementRe, xmpAttributeRe} {
+		for _, match := range re.FindAllSubmatch(packet, -1) {
+			value := strings.TrimSpace(string(match[2]))
+			if value != "" {
+				properties[string(match[1])] = value
+			}
+		}
+	}
+	return properties
+}
@@ -27,7 +27,7 @@ func main() {
 	if err != nil {
 		logger.Fatal().Err(err).Msg("error loading config")
 	}
-	logger.Debug().Any("SampleData", cfg.SampleData).Msg("SampleData")
+	logger.Debug().Any("DocumentTypes", cfg.DocumentTypes).Any("SampleData", cfg.SampleData).Msg("document types")
 	app := app.New(cfg, logger)
 	if err := app.Init(); err != nil {
 		logger.Fatal().Err(err).Msg("initialization error")
@@ -0,0 +1,572 @@
+package api
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/legislator"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/store"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+	"github.com/rs/zerolog"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+
+	// dateLayout is the layout of the date filters:
+	dateLayout = "2006-01-02"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+var (
+	errNotFound  = errors.New("not found")
+	errBadFilter = errors.New("invalid filter")
+)
+
+// Server is the read-only REST API over the store
+// Only votes of exportable documents are served, see document.Document.Exportable:
+type Server struct {
+	cfg    *config.Config
+	store  *store.Store
+	types  *types.Registry
+	logger zerolog.Logger
+}
+
+// Page is a paginated list:
+type Page struct {
+	Items   interface{} `json:"items"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+	Total   int         `json:"total"`
+}
+
+// Document is the API view of a document:
+type Document struct {
+	ID             string               `json:"id"`
+	Type           types.DocumentType   `json:"type"`
+	SourceURL      string               `json:"source_url,omitempty"`
+	PDFURL         string               `json:"pdf_url"`
+	ImageURLs      []string             `json:"image_urls"`
+	Classification *document.ResultInfo `json:"classification,omitempty"`
+	Extraction     *document.ResultInfo `json:"extraction,omitempty"`
+	Validation     *document.Validation `json:"validation,omitempty"`
+	Exportable     bool                 `json:"exportable"`
+	// VoteID is set when the votes of the document are available:
+	VoteID string `json:"vote_id,omitempty"`
+}
+
+// VoteEvent is a single vote, there's one per exportable document:
+type VoteEvent struct {
+	ID         string              `json:"id"`
+	DocumentID string              `json:"document_id"`
+	SessionID  string              `json:"session_id"`
+	Chamber    string              `json:"chamber,omitempty"`
+	Date       string              `json:"date"`
+	Time       string              `json:"time,omitempty"`
+	Subject    string              `json:"subject,omitempty"`
+	Totals     map[vote.Option]int `json:"totals,omitempty"`
+	Counts     map[vote.Option]int `json:"counts"`
+	// Votes is only set when retrieving a single vote event:
+	Votes []*Vote `json:"votes,omitempty"`
+}
+
+// Vote is an individual legislator vote:
+type Vote struct {
+	LegislatorID string      `json:"legislator_id"`
+	Name         string      `json:"name"`
+	Option       vote.Option `json:"option"`
+	// Party and Bloc are the affiliations at the vote date:
+	Party string `json:"party,omitempty"`
+	Bloc  string `json:"bloc,omitempty"`
+}
+
+// Legislator is a legislator that appears in the votes:
+type Legislator struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Registered is set when the legislator is in the registry, otherwise the ID is derived from the name:
+	Registered bool `json:"registered"`
+	VoteCount  int  `json:"vote_count"`
+	// Votes is only set when retrieving a single legislator:
+	Votes []*LegislatorVote `json:"votes,omitempty"`
+}
+
+// LegislatorVote is a vote of a legislator:
+type LegislatorVote struct {
+	VoteID string      `json:"vote_id"`
+	Date   string      `json:"date"`
+	Option vote.Option `json:"option"`
+}
+
+// Session groups the votes of a chamber in a single day:
+type Session struct {
+	ID      string   `json:"id"`
+	Chamber string   `json:"chamber,omitempty"`
+	Date    string   `json:"date"`
+	VoteIDs []string `json:"vote_ids"`
+}
+
+// index holds the API views built from the store:
+type index struct {
+	documents   []*Document
+	votes       []*VoteEvent
+	legislators []*Legislator
+	sessions    []*Session
+}
+
+// buildIndex builds the API views from the documents in the store, sorted by ID or date:
+func (s *Server) buildIndex() *index {
+	idx := &index{
+		documents:   make([]*Document, 0),
+		votes:       make([]*VoteEvent, 0),
+		legislators: make([]*Legislator, 0),
+		sessions:    make([]*Session, 0),
+	}
+	legislators := make(map[string]*Legislator)
+	sessions := make(map[string]*Session)
+	persons := make(map[string]*legislator.Person)
+	for _, p := range s.store.RetrieveLegislators() {
+		persons[p.ID] = p
+	}
+	docs := s.store.RetrieveDocuments()
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].ID < docs[j].ID
+	})
+	for _, d := range docs {
+		view := &Document{
+			ID:             d.ID,
+			Type:           d.Type,
+			SourceURL:      d.SourceURL,
+			PDFURL:         "/static/pdf/" + url.PathEscape(d.ID),
+			ImageURLs:      make([]string, 0, len(d.ImagePaths)),
+			Classification: d.Classification,
+			Extraction:     d.Extraction,
+			Validation:     d.Validation,
+			Exportable:     d.Exportable(),
+		}
+		for page := range d.ImagePaths {
+			view.ImageURLs = append(view.ImageURLs, fmt.Sprintf("/static/images/%s/%d", url.PathEscape(d.ID), page))
+		}
+		idx.documents = append(idx.documents, view)
+		if !view.Exportable {
+			continue
+		}
+		view.VoteID = d.ID
+		chamber := d.VoteChamber(s.types)
+		sessionID := d.Votes.Date
+		if chamber != "" {
+			sessionID = chamber + "-" + d.Votes.Date
+		}
+		event := &VoteEvent{
+			ID:         d.ID,
+			DocumentID: d.ID,
+			SessionID:  sessionID,
+			Chamber:    chamber,
+			Date:       d.Votes.Date,
+			Time:       d.Votes.Time,
+			Subject:    d.Votes.Subject,
+			Totals:     d.Votes.Totals,
+			Counts:     d.Votes.Count(),
+			Votes:      make([]*Vote, 0, len(d.Votes.Votes)),
+		}
+		for _, v := range d.Votes.Votes {
+			// Names that weren't resolved to the registry get an ID derived from the name:
+			id, name := v.LegislatorID, v.Name
+			if person, ok := persons[id]; ok {
+				name = person.Name
+			} else {
+				id = legislator.Slug(v.Name)
+			}
+			event.Votes = append(event.Votes, &Vote{LegislatorID: id, Name: v.Name, Option: v.Option, Party: v.Party, Bloc: v.Bloc})
+			view, ok := legislators[id]
+			if !ok {
+				view = &Legislator{ID: id, Name: name, Registered: persons[id] != nil}
+				legislators[id] = view
+			}
+			view.VoteCount++
+			view.Votes = append(view.Votes, &LegislatorVote{VoteID: event.ID, Date: event.Date, Option: v.Option})
+		}
+		idx.votes = append(idx.votes, event)
+		session, ok := sessions[sessionID]
+		if !ok {
+			session = &Session{ID: sessionID, Chamber: chamber, Date: event.Date}
+			sessions[sessionID] = session
+		}
+		session.VoteIDs = append(session.VoteIDs, event.ID)
+	}
+	sort.SliceStable(idx.votes, func(i, j int) bool {
+		if idx.votes[i].Date != idx.votes[j].Date {
+			return idx.votes[i].Date < idx.votes[j].Date
+		}
+		return idx.votes[i].Time < idx.votes[j].Time
+	})
+	for _, legislator := range legislators {
+		idx.legislators = append(idx.legislators, legislator)
+	}
+	sort.Slice(idx.legislators, func(i, j int) bool {
+		return idx.legislators[i].ID < idx.legislators[j].ID
+	})
+	for _, session := range sessions {
+		idx.sessions = append(idx.sessions, session)
+	}
+	sort.Slice(idx.sessions, func(i, j int) bool {
+		if idx.sessions[i].Date != idx.sessions[j].Date {
+			return idx.sessions[i].Date < idx.sessions[j].Date
+		}
+		return idx.sessions[i].Chamber < idx.sessions[j].Chamber
+	})
+	return idx
+}
+
+// filters holds the common query filters:
+type filters struct {
+	docType    string
+	chamber    string
+	from       string
+	to         string
+	name       string
+	legislator string
+	exportable string
+}
+
+// parseFilters reads the filters from the query string, dates use the YYYY-MM-DD format:
+func parseFilters(query url.Values) (*filters, error) {
+	f := &filters{
+		docType:    query.Get("type"),
+		chamber:    query.Get("chamber"),
+		from:       query.Get("from"),
+		to:         query.Get("to"),
+		name:       vote.NormalizeName(query.Get("name")),
+		legislator: query.Get("legislator"),
+		exportable: query.Get("exportable"),
+	}
+	for _, date := range []string{f.from, f.to} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return nil, fmt.Errorf("%w: date %q", errBadFilter, date)
+		}
+	}
+	if f.exportable != "" && f.exportable != "true" && f.exportable != "false" {
+		return nil, fmt.Errorf("%w: exportable %q", errBadFilter, f.exportable)
+	}
+	return f, nil
+}
+
+// matchDate reports if a date is within the from and to filters:
+func (f *filters) matchDate(date string) bool {
+	if f.from != "" && date < f.from {
+		return false
+	}
+	if f.to != "" && date > f.to {
+		return false
+	}
+	return true
+}
+
+// paginate returns the requested page of a list:
+func paginate[T any](items []T, query url.Values) (*Page, error) {
+	page, perPage := 1, defaultPerPage
+	if raw := query.Get("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("%w: page %q", errBadFilter, raw)
+		}
+		page = n
+	}
+	if raw := query.Get("per_page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPerPage {
+			return nil, fmt.Errorf("%w: per_page %q, the maximum is %d", errBadFilter, raw, maxPerPage)
+		}
+		perPage = n
+	}
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	return &Page{
+		Items:   items[start:end],
+		Page:    page,
+		PerPage: perPage,
+		Total:   len(items),
+	}, nil
+}
+
+// documents handles /api/documents and /api/documents/{id}:
+func (s *Server) documents(r *http.Request, id string) (interface{}, error) {
+	idx := s.buildIndex()
+	if id != "" {
+		for _, d := range idx.documents {
+			if d.ID == id {
+				return d, nil
+			}
+		}
+		return nil, errNotFound
+	}
+	f, err := parseFilters(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]*Document, 0, len(idx.documents))
+	for _, d := range idx.documents {
+		if f.docType != "" && string(d.Type) != f.docType {
+			continue
+		}
+		if f.exportable != "" && strconv.FormatBool(d.Exportable) != f.exportable {
+			continue
+		}
+		docs = append(docs, d)
+	}
+	return paginate(docs, r.URL.Query())
+}
+
+// votes handles /api/votes and /api/votes/{id}, individual votes are only listed for a single vote event:
+func (s *Server) votes(r *http.Request, id string) (interface{}, error) {
+	idx := s.buildIndex()
+	if id != "" {
+		for _, event := range idx.votes {
+			if event.ID == id {
+				return event, nil
+			}
+		}
+		return nil, errNotFound
+	}
+	f, err := parseFilters(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	docTypes := make(map[string]types.DocumentType)
+	for _, d := range idx.documents {
+		docTypes[d.ID] = d.Type
+	}
+	events := make([]*VoteEvent, 0, len(idx.votes))
+	for _, event := range idx.votes {
+		if f.chamber != "" && event.Chamber != f.chamber {
+			continue
+		}
+		if f.docType != "" && string(docTypes[event.DocumentID]) != f.docType {
+			continue
+		}
+		if !f.matchDate(event.Date) || (f.legislator != "" && !hasLegislator(event, f.legislator)) {
+			continue
+		}
+		summary := *event
+		summary.Votes = nil
+		events = append(events, &summary)
+	}
+	return paginate(events, r.URL.Query())
+}
+
+// hasLegislator reports if a legislator voted in a vote event:
+func hasLegislator(event *VoteEvent, legislatorID string) bool {
+	for _, v := range event.Votes {
+		if v.LegislatorID == legislatorID {
+			return true
+		}
+	}
+	return false
+}
+
+// legislators handles /api/legislators and /api/legislators/{id}, votes are only listed for a single legislator:
+func (s *Server) legislators(r *http.Request, id string) (interface{}, error) {
+	idx := s.buildIndex()
+	if id != "" {
+		for _, legislator := range idx.legislators {
+			if legislator.ID == id {
+				return legislator, nil
+			}
+		}
+		return nil, errNotFound
+	}
+	f, err := parseFilters(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	legislators := make([]*Legislator, 0, len(idx.legislators))
+	for _, legislator := range idx.legislators {
+		if f.name != "" && !strings.Contains(vote.NormalizeName(legislator.Name), f.name) {
+			continue
+		}
+		summary := *legislator
+		summary.Votes = nil
+		legislators = append(legislators, &summary)
+	}
+	return paginate(legislators, r.URL.Query())
+}
+
+// sessions handles /api/sessions and /api/sessions/{id}:
+func (s *Server) sessions(r *http.Request, id string) (interface{}, error) {
+	idx := s.buildIndex()
+	if id != "" {
+		for _, session := range idx.sessions {
+			if session.ID == id {
+				return session, nil
+			}
+		}
+		return nil, errNotFound
+	}
+	f, err := parseFilters(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]*Session, 0, len(idx.sessions))
+	for _, session := range idx.sessions {
+		if f.chamber != "" && session.Chamber != f.chamber {
+			continue
+		}
+		if !f.matchDate(session.Date) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return paginate(sessions, r.URL.Query())
+}
+
+// resource wraps a list/item handler, the path after the prefix is the item ID:
+func (s *Server) resource(prefix string, handler func(r *http.Request, id string) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			s.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		id, err := url.PathUnescape(strings.TrimPrefix(strings.TrimPrefix(r.URL.EscapedPath(), prefix), "/"))
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		data, err := handler(r, id)
+		switch {
+		case errors.Is(err, errNotFound):
+			s.writeError(w, http.StatusNotFound, err)
+			return
+		case errors.Is(err, errBadFilter):
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		case err != nil:
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		rawData, err := json.Marshal(data)
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.write(w, r, "application/json", rawData)
+	}
+}
+
+// write sends a response with an ETag, answering conditional requests with 304 Not Modified:
+func (s *Server) write(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == etag || candidate == "*" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if _, err := w.Write(body); err != nil {
+		s.logger.Debug().Msgf("error writing response: %s", err)
+	}
+}
+
+// writeError sends a JSON error:
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		s.logger.Err(err).Msg("API error")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// openAPI serves the OpenAPI description:
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	s.write(w, r, "application/json", openAPISpec)
+}
+
+// pdf serves the original PDF of a document, the path is /static/pdf/<document ID>:
+func (s *Server) pdf(w http.ResponseWriter, r *http.Request) {
+	d := s.store.RetrieveDocument(strings.TrimPrefix(r.URL.Path, "/static/pdf/"))
+	if d == nil || d.PDFPath == "" {
+		s.writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	http.ServeFile(w, r, d.PDFPath)
+}
+
+// image serves a rendered page of a document, the path is /static/images/<document ID>/<page index>:
+func (s *Server) image(w http.ResponseWriter, r *http.Request) {
+	id, rawPage, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/static/images/"), "/")
+	if !ok {
+		s.writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	d := s.store.RetrieveDocument(id)
+	page, err := strconv.Atoi(rawPage)
+	if d == nil || err != nil || page < 0 || page >= len(d.ImagePaths) {
+		s.writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	http.ServeFile(w, r, d.ImagePaths[page])
+}
+
+// Handler returns the HTTP handler of the API, PDFs and page images are served under /static:
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", s.openAPI)
+	mux.HandleFunc("/api/documents", s.resource("/api/documents", s.documents))
+	mux.HandleFunc("/api/documents/", s.resource("/api/documents", s.documents))
+	mux.HandleFunc("/api/votes", s.resource("/api/votes", s.votes))
+	mux.HandleFunc("/api/votes/", s.resource("/api/votes", s.votes))
+	mux.HandleFunc("/api/legislators", s.resource("/api/legislators", s.legislators))
+	mux.HandleFunc("/api/legislators/", s.resource("/api/legislators", s.legislators))
+	mux.HandleFunc("/api/sessions", s.resource("/api/sessions", s.sessions))
+	mux.HandleFunc("/api/sessions/", s.resource("/api/sessions", s.sessions))
+	mux.HandleFunc("/static/pdf/", s.pdf)
+	mux.HandleFunc("/static/images/", s.image)
+	return mux
+}
+
+// ListenAndServe starts the API on the given address:
+func (s *Server) ListenAndServe(addr string) error {
+	s.logger.Info().Msgf("API listening on http://%s", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// New initializes the API server
+// The store is read once, the server must be restarted to serve new documents:
+func New(cfg *config.Config, store *store.Store, registry *types.Registry, logger zerolog.Logger) *Server {
+	return &Server{
+		cfg:    cfg,
+		store:  store,
+		types:  registry,
+		logger: logger,
+	}
+}
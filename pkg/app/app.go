@@ -1,12 +1,23 @@
 package app
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/api"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/cluster"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/export"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/processor"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/review"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/store"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
 	"github.com/rs/zerolog"
 	"github.com/urfave/cli/v2"
 )
@@ -17,6 +28,7 @@ type App struct {
 	logger    zerolog.Logger
 	cfg       *config.Config
 	store     *store.Store
+	types     *types.Registry
 	processor *processor.Processor
 }
 
@@ -40,25 +52,55 @@ func (a *App) Init() error {
 	if a.cfg.SamplesPath == "" {
 		a.cfg.SamplesPath = filepath.Join(cwd, defaultSamplePath)
 	}
+	if a.cfg.TemplatesPath == "" {
+		a.cfg.TemplatesPath = filepath.Join(cwd, defaultTemplatesPath)
+	}
+	if a.cfg.OpenAIConfig.CachePath == "" {
+		a.cfg.OpenAIConfig.CachePath = filepath.Join(cwd, defaultCachePath)
+	}
+	if _, err := a.cfg.OpenAIConfig.GetCacheTTL(); err != nil {
+		return err
+	}
 	for _, d := range []string{
 		a.cfg.PDFPath,
 		a.cfg.ImagePath,
 		a.cfg.JSONPath,
+		a.cfg.OpenAIConfig.CachePath,
 	} {
 		if err := os.MkdirAll(d, 0755); err != nil {
 			return err
 		}
 	}
 
+	// Load and validate document types:
+	a.types, err = types.NewRegistry(a.cfg)
+	if err != nil {
+		return err
+	}
+	if err := a.types.Validate(); err != nil {
+		return err
+	}
+
 	// Init store:
-	a.store = store.New(a.cfg, a.logger)
+	a.store = store.New(a.cfg, a.types, a.logger)
 
 	if a.store.Init(); err != nil {
 		return err
 	}
 
 	// Init processor:
-	a.processor = processor.New(a.cfg, a.store, a.logger)
+	a.processor = processor.New(a.cfg, a.store, a.types, a.logger)
+	return a.processor.Init()
+}
+
+// before applies the global flags to the configuration:
+func (a *App) before(c *cli.Context) error {
+	if c.Bool("no-cache") {
+		a.cfg.OpenAIConfig.DisableCache = true
+	}
+	if c.IsSet("presupuesto") {
+		a.cfg.OpenAIConfig.MaxCost = c.Float64("presupuesto")
+	}
 	return nil
 }
 
@@ -66,7 +108,20 @@ func (a *App) fetch(c *cli.Context) error {
 	return nil
 }
 
+// logUsage prints the token usage and cost of the run:
+func (a *App) logUsage() {
+	summary := a.processor.UsageSummary()
+	if summary.Calls == 0 {
+		return
+	}
+	for documentID, totals := range summary.ByDocument {
+		a.logger.Debug().Msgf("usage for %s: %s", documentID, totals)
+	}
+	a.logger.Info().Msgf("total usage: %s", &summary.Totals)
+}
+
 func (a *App) classify(c *cli.Context) error {
+	defer a.logUsage()
 	if err := a.processor.Classify(); err != nil {
 		return err
 	}
@@ -74,18 +129,240 @@ func (a *App) classify(c *cli.Context) error {
 }
 
 func (a *App) extract(c *cli.Context) error {
-	if err := a.processor.Extract(); err != nil {
+	defer a.logUsage()
+	if err := a.processor.Extract(c.Bool("comparar"), c.Bool("forzar")); err != nil {
 		return err
 	}
 	return nil
 }
 
+func (a *App) evaluate(c *cli.Context) error {
+	defer a.logUsage()
+	report, err := a.processor.Evaluate(c.String("directorio"))
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
+	if output := c.String("salida"); output != "" {
+		rawReport, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(output, rawReport, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) cluster(c *cli.Context) error {
+	clusters, err := a.processor.Cluster(c.Float64("umbral"))
+	if err != nil {
+		return err
+	}
+	for _, cl := range clusters {
+		fmt.Printf("grupo %d: %d documentos\n", cl.ID, len(cl.Members))
+		for _, documentID := range cl.Representatives {
+			d := a.store.RetrieveDocument(documentID)
+			fmt.Printf("  %s\t%s\n", documentID, strings.Join(d.ImagePaths, ", "))
+		}
+	}
+	return nil
+}
+
+func (a *App) promoteCluster(c *cli.Context) error {
+	return a.processor.PromoteCluster(c.Int("grupo"), c.String("etiqueta"))
+}
+
+func (a *App) listSamples(c *cli.Context) error {
+	for _, definition := range a.types.Definitions() {
+		fmt.Printf("%s\t%s\n", definition.Name, strings.Join(definition.Samples, ", "))
+	}
+	return nil
+}
+
+func (a *App) addSample(c *cli.Context) error {
+	return a.processor.AddSample(c.String("etiqueta"), c.String("documento"))
+}
+
+func (a *App) removeSample(c *cli.Context) error {
+	return a.processor.RemoveSample(c.String("etiqueta"), c.String("archivo"))
+}
+
+func (a *App) validateSamples(c *cli.Context) error {
+	problems, err := a.processor.ValidateSamples()
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found", len(problems))
+	}
+	fmt.Println("ok")
+	return nil
+}
+
+func (a *App) validate(c *cli.Context) error {
+	var override *document.ValidationOverride
+	if c.Bool("aceptar") {
+		if c.String("motivo") == "" {
+			return errors.New("accepting a document requires a reason")
+		}
+		override = &document.ValidationOverride{
+			By:     c.String("revisor"),
+			Reason: c.String("motivo"),
+			At:     time.Now(),
+		}
+	}
+	docs, err := a.processor.Validate(c.String("documento"), override)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, d := range docs {
+		if d.Validation.Passed() {
+			continue
+		}
+		failed++
+		status := "bloqueado"
+		if d.Validation.Override != nil {
+			status = "aceptado"
+		}
+		fmt.Printf("%s (%s)\n", d.ID, status)
+		for _, validationErr := range d.Validation.Errors {
+			fmt.Printf("  %s: %s\n", validationErr.Check, validationErr.Message)
+		}
+	}
+	fmt.Printf("%d documentos validados, %d con errores\n", len(docs), failed)
+	return nil
+}
+
+func (a *App) review(c *cli.Context) error {
+	server, err := review.New(a.cfg, a.store, a.types, a.processor, c.String("revisor"), a.logger)
+	if err != nil {
+		return err
+	}
+	return server.ListenAndServe(c.String("direccion"))
+}
+
+func (a *App) serve(c *cli.Context) error {
+	return api.New(a.cfg, a.store, a.types, a.logger).ListenAndServe(c.String("direccion"))
+}
+
+func (a *App) importLegislators(c *cli.Context) error {
+	added, updated, err := a.processor.ImportLegislators(c.String("archivo"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d legisladores nuevos, %d actualizados\n", added, updated)
+	return nil
+}
+
+func (a *App) importMemberships(c *cli.Context) error {
+	added, updated, err := a.processor.ImportMemberships(c.String("archivo"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d afiliaciones nuevas, %d actualizadas\n", added, updated)
+	fmt.Println("ejecutar \"legisladores resolver\" para actualizar los votos extraídos")
+	return nil
+}
+
+func (a *App) listLegislators(c *cli.Context) error {
+	for _, p := range a.store.RetrieveLegislators() {
+		fmt.Printf("%s\t%s", p.ID, p.Name)
+		if len(p.Aliases) > 0 {
+			fmt.Printf("\t%s", strings.Join(p.Aliases, " | "))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func (a *App) addLegislatorAlias(c *cli.Context) error {
+	return a.store.AddLegislatorAlias(c.String("id"), c.String("alias"))
+}
+
+func (a *App) resolveLegislators(c *cli.Context) error {
+	docs, err := a.processor.ResolveLegislators()
+	if err != nil {
+		return err
+	}
+	for _, d := range docs {
+		fmt.Println(d.ID)
+		for _, name := range d.UnresolvedNames {
+			fmt.Printf("  %s\t%s\n", name.Name, strings.Join(name.Candidates, ", "))
+		}
+	}
+	fmt.Printf("%d documentos con nombres sin resolver\n", len(docs))
+	return nil
+}
+
+func (a *App) export(c *cli.Context) error {
+	exporter := export.New(a.store, a.types, a.logger)
+	filter := &export.Filter{
+		From:    c.String("desde"),
+		To:      c.String("hasta"),
+		Chamber: c.String("camara"),
+		Type:    types.DocumentType(c.String("tipo")),
+	}
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	var write export.TableWriter
+	switch format := c.String("formato"); format {
+	case exportFormatPopolo:
+		// The log is written to the standard output, so the export always goes to a file:
+		output := c.String("salida")
+		if output == "" {
+			output = defaultPopoloOutput
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		if err := exporter.WritePopolo(f, filter); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	case exportFormatCSV:
+		write = export.WriteCSV
+	case exportFormatNDJSON:
+		write = export.WriteNDJSON
+	case exportFormatParquet:
+		write = export.WriteParquet
+	default:
+		return fmt.Errorf("unknown export format: %s", format)
+	}
+	// Table formats write a file per table to the output directory:
+	dir := c.String("salida")
+	if dir == "" {
+		dir = "."
+	}
+	_, err := exporter.WriteTables(dir, "."+c.String("formato"), write, filter)
+	return err
+}
+
 // New takes a configuration and logger and returns app:
 func New(cfg *config.Config, logger zerolog.Logger) *App {
 	var app App
 	app.logger = logger
 	app.cfg = cfg
 	app.App = &cli.App{
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "no-cache",
+				Usage: "Ignorar las respuestas guardadas en el cache del modelo",
+			},
+			&cli.Float64Flag{
+				Name:  "presupuesto",
+				Usage: "Costo máximo en USD de la ejecución, se detiene al alcanzarlo",
+			},
+		},
+		Before: app.before,
 		Commands: []*cli.Command{
 			{
 				Name:    "descargar",
@@ -104,6 +381,255 @@ func New(cfg *config.Config, logger zerolog.Logger) *App {
 				Aliases: []string{"e"},
 				Usage:   "Procesar y extraer datos de los documentos de votación",
 				Action:  app.extract,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "comparar",
+						Usage: "Extraer con todos los extractores del tipo y marcar las diferencias para revisión",
+					},
+					&cli.BoolFlag{
+						Name:  "forzar",
+						Usage: "Volver a extraer también los documentos ya extraídos o corregidos por un revisor",
+					},
+				},
+			},
+			{
+				Name:   "agrupar",
+				Usage:  "Agrupar documentos sin clasificar por su diseño para descubrir nuevos formatos",
+				Action: app.cluster,
+				Flags: []cli.Flag{
+					&cli.Float64Flag{
+						Name:  "umbral",
+						Usage: "Distancia máxima entre grupos para unirlos",
+						Value: cluster.DefaultThreshold,
+					},
+				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "promover",
+						Usage:  "Agregar los documentos representativos de un grupo como muestras de una etiqueta",
+						Action: app.promoteCluster,
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:     "grupo",
+								Usage:    "Número de grupo",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "etiqueta",
+								Usage:    "Etiqueta de la muestra",
+								Required: true,
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:  "muestras",
+				Usage: "Administrar las muestras de cada tipo de documento",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "listar",
+						Usage:  "Listar las muestras por etiqueta",
+						Action: app.listSamples,
+					},
+					{
+						Name:   "agregar",
+						Usage:  "Agregar un documento existente como muestra",
+						Action: app.addSample,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "etiqueta",
+								Usage:    "Etiqueta de la muestra",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "documento",
+								Usage:    "ID del documento",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "quitar",
+						Usage:  "Quitar una muestra",
+						Action: app.removeSample,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "etiqueta",
+								Usage:    "Etiqueta de la muestra",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "archivo",
+								Usage:    "Nombre del archivo de la muestra",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "validar",
+						Usage:  "Validar que las muestras se puedan procesar y distinguir entre sí",
+						Action: app.validateSamples,
+					},
+				},
+			},
+			{
+				Name:   "validar",
+				Usage:  "Validar los votos extraídos, los documentos con errores no se exportan salvo que se acepten",
+				Action: app.validate,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "documento",
+						Usage: "ID del documento, por defecto se validan todos",
+					},
+					&cli.BoolFlag{
+						Name:  "aceptar",
+						Usage: "Aceptar el documento para exportarlo aunque tenga errores",
+					},
+					&cli.StringFlag{
+						Name:  "motivo",
+						Usage: "Motivo por el que se acepta el documento",
+					},
+					&cli.StringFlag{
+						Name:  "revisor",
+						Usage: "Nombre de quien acepta el documento",
+					},
+				},
+			},
+			{
+				Name:   "revisar",
+				Usage:  "Abrir la interfaz web para revisar y corregir clasificaciones y votos",
+				Action: app.review,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "direccion",
+						Usage: "Dirección donde escuchar",
+						Value: defaultReviewAddr,
+					},
+					&cli.StringFlag{
+						Name:    "revisor",
+						Usage:   "Nombre del revisor",
+						EnvVars: []string{"USER"},
+					},
+				},
+			},
+			{
+				Name:   "servir",
+				Usage:  "Servir los documentos y votos como una API REST de solo lectura",
+				Action: app.serve,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "direccion",
+						Usage: "Dirección donde escuchar",
+						Value: defaultAPIAddr,
+					},
+				},
+			},
+			{
+				Name:  "legisladores",
+				Usage: "Administrar el registro de legisladores",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "importar",
+						Usage:  "Importar legisladores desde un CSV con las columnas id, name y aliases -separados por |-",
+						Action: app.importLegislators,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "archivo",
+								Usage:    "Archivo CSV",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "afiliaciones",
+						Usage:  "Importar afiliaciones a partidos y bancadas desde un CSV con las columnas person_id, organization, classification -party o bloc-, role, start y end",
+						Action: app.importMemberships,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "archivo",
+								Usage:    "Archivo CSV",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "listar",
+						Usage:  "Listar los legisladores registrados",
+						Action: app.listLegislators,
+					},
+					{
+						Name:   "alias",
+						Usage:  "Agregar un alias a un legislador",
+						Action: app.addLegislatorAlias,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:     "id",
+								Usage:    "ID del legislador",
+								Required: true,
+							},
+							&cli.StringFlag{
+								Name:     "alias",
+								Usage:    "Nombre tal como aparece en los documentos",
+								Required: true,
+							},
+						},
+					},
+					{
+						Name:   "resolver",
+						Usage:  "Asociar los nombres de los votos extraídos a los legisladores registrados y sus afiliaciones",
+						Action: app.resolveLegislators,
+					},
+				},
+			},
+			{
+				Name:   "exportar",
+				Usage:  "Exportar los votos validados",
+				Action: app.export,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "formato",
+						Aliases: []string{"format"},
+						Usage:   "Formato de exportación: popolo, csv, ndjson o parquet",
+						Value:   exportFormatPopolo,
+					},
+					&cli.StringFlag{
+						Name:  "salida",
+						Usage: "Archivo de salida para popolo, por defecto popolo.json. Para csv, ndjson y parquet es el directorio donde se escriben votes y vote_events, por defecto el actual",
+					},
+					&cli.StringFlag{
+						Name:  "desde",
+						Usage: "Exportar las votaciones desde esta fecha, en formato AAAA-MM-DD",
+					},
+					&cli.StringFlag{
+						Name:  "hasta",
+						Usage: "Exportar las votaciones hasta esta fecha inclusive, en formato AAAA-MM-DD",
+					},
+					&cli.StringFlag{
+						Name:  "camara",
+						Usage: "Exportar solo las votaciones de esta cámara, por ejemplo senadores",
+					},
+					&cli.StringFlag{
+						Name:  "tipo",
+						Usage: "Exportar solo los documentos de este tipo",
+					},
+				},
+			},
+			{
+				Name:   "evaluar",
+				Usage:  "Evaluar la clasificación y extracción contra documentos etiquetados",
+				Action: app.evaluate,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "directorio",
+						Usage:    "Directorio con los PDFs y sus archivos JSON esperados",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "salida",
+						Usage: "Archivo JSON donde guardar el reporte",
+					},
+				},
 			},
 		},
 	}
@@ -1,11 +1,22 @@
 package app
 
 const (
-	defaultPDFPath    = "data/pdf"
-	defaultImagePath  = "data/image"
-	defaultJSONPath   = "data/json"
-	defaultStorePath  = "data/data.json"
-	defaultSamplePath = "sample"
+	defaultPDFPath       = "data/pdf"
+	defaultImagePath     = "data/image"
+	defaultJSONPath      = "data/json"
+	defaultStorePath     = "data/data.json"
+	defaultSamplePath    = "sample"
+	defaultCachePath     = "data/cache"
+	defaultTemplatesPath = "templates"
+	defaultReviewAddr    = "localhost:8080"
+	defaultAPIAddr       = "localhost:8081"
+
+	exportFormatPopolo  = "popolo"
+	exportFormatCSV     = "csv"
+	exportFormatNDJSON  = "ndjson"
+	exportFormatParquet = "parquet"
+
+	defaultPopoloOutput = "popolo.json"
 
 	baseURL = "https://silpy.congreso.gov.py/web/votaciones"
 )
@@ -0,0 +1,239 @@
+package cluster
+
+import (
+	"errors"
+	"image"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+)
+
+const (
+	// gridWidth and gridHeight set the size of the downscaled page used as visual feature:
+	gridWidth  = 24
+	gridHeight = 32
+
+	// maxRepresentatives is the amount of representative members kept per cluster:
+	maxRepresentatives = 3
+
+	// DefaultThreshold is the max average distance between clusters that get merged:
+	DefaultThreshold = 0.15
+)
+
+var (
+	errNoImages = errors.New("document has no rendered images")
+)
+
+// Features describes the visual and text layout of a document:
+type Features struct {
+	// Visual is the first page downscaled to a grayscale grid, values between 0 and 1:
+	Visual []float64 `json:"visual"`
+	// PageCount is the amount of pages:
+	PageCount int `json:"page_count"`
+	// AspectRatio is the width/height ratio of the first page:
+	AspectRatio float64 `json:"aspect_ratio"`
+	// TextChars is the amount of non space characters in the text layer:
+	TextChars int `json:"text_chars"`
+	// TextPages is the ratio of pages with a text layer:
+	TextPages float64 `json:"text_pages"`
+}
+
+// ExtractFeatures computes the features of a rendered document:
+func ExtractFeatures(d *document.Document) (*Features, error) {
+	if len(d.ImagePaths) == 0 {
+		return nil, errNoImages
+	}
+	f, err := os.Open(d.ImagePaths[0])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	features := &Features{
+		Visual:      downscale(img),
+		PageCount:   len(d.ImagePaths),
+		AspectRatio: float64(img.Bounds().Dx()) / float64(img.Bounds().Dy()),
+	}
+	texts, err := pdf2png.GetPageTexts(d.PDFPath)
+	if err != nil {
+		return nil, err
+	}
+	pagesWithText := 0
+	for _, text := range texts {
+		chars := len(strings.Join(strings.Fields(text), ""))
+		features.TextChars += chars
+		if chars > 0 {
+			pagesWithText++
+		}
+	}
+	if len(texts) > 0 {
+		features.TextPages = float64(pagesWithText) / float64(len(texts))
+	}
+	return features, nil
+}
+
+// downscale averages the image intensity into a fixed grid:
+func downscale(img image.Image) []float64 {
+	bounds := img.Bounds()
+	sums := make([]float64, gridWidth*gridHeight)
+	counts := make([]float64, gridWidth*gridHeight)
+	// Sampling every other pixel is enough for the grid size:
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		gy := (y - bounds.Min.Y) * gridHeight / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x += 2 {
+			gx := (x - bounds.Min.X) * gridWidth / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			luminance := (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 0xffff
+			sums[gy*gridWidth+gx] += luminance
+			counts[gy*gridWidth+gx]++
+		}
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= counts[i]
+		}
+	}
+	return sums
+}
+
+// Distance returns a dissimilarity score between two documents,
+// zero means identical features:
+func Distance(a, b *Features) float64 {
+	var visual float64
+	for i := range a.Visual {
+		if i >= len(b.Visual) {
+			break
+		}
+		diff := a.Visual[i] - b.Visual[i]
+		visual += diff * diff
+	}
+	if len(a.Visual) > 0 {
+		visual = math.Sqrt(visual / float64(len(a.Visual)))
+	}
+	pages := math.Min(math.Abs(float64(a.PageCount-b.PageCount)), 5) / 5
+	chars := math.Abs(math.Log10(float64(a.TextChars+1))-math.Log10(float64(b.TextChars+1))) / 5
+	// Most pages are largely white so the visual difference gets a higher weight:
+	return 2*visual +
+		0.5*math.Abs(a.TextPages-b.TextPages) +
+		0.25*chars +
+		0.25*pages +
+		math.Abs(a.AspectRatio-b.AspectRatio)
+}
+
+// Cluster is a group of documents with a similar layout:
+type Cluster struct {
+	ID int `json:"id"`
+	// Members are the document IDs:
+	Members []string `json:"members"`
+	// Representatives are the members closest to the rest of the cluster:
+	Representatives []string `json:"representatives"`
+}
+
+// Group clusters documents using average linkage agglomerative clustering
+// Clusters are merged while their average distance is below the threshold,
+// the result is sorted by size. Document distances are computed once and the
+// distances between clusters are updated as they merge:
+func Group(features map[string]*Features, threshold float64) []*Cluster {
+	ids := make([]string, 0, len(features))
+	for id := range features {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	documentDistances := make([][]float64, len(ids))
+	for i := range ids {
+		documentDistances[i] = make([]float64, len(ids))
+		for j := 0; j < i; j++ {
+			d := Distance(features[ids[i]], features[ids[j]])
+			documentDistances[i][j], documentDistances[j][i] = d, d
+		}
+	}
+	// groups holds the document indexes of every cluster, merged clusters are set to nil
+	// and distances holds the average distance between clusters:
+	groups := make([][]int, len(ids))
+	distances := make([][]float64, len(ids))
+	for i := range ids {
+		groups[i] = []int{i}
+		distances[i] = append([]float64{}, documentDistances[i]...)
+	}
+	for {
+		bestI, bestJ, best := -1, -1, math.Inf(1)
+		for i := range groups {
+			if groups[i] == nil {
+				continue
+			}
+			for j := i + 1; j < len(groups); j++ {
+				if groups[j] != nil && distances[i][j] < best {
+					bestI, bestJ, best = i, j, distances[i][j]
+				}
+			}
+		}
+		if bestI < 0 || best > threshold {
+			break
+		}
+		// The average distance to the merged cluster is the size weighted average of both distances:
+		sizeI, sizeJ := float64(len(groups[bestI])), float64(len(groups[bestJ]))
+		for k := range groups {
+			if groups[k] == nil || k == bestI || k == bestJ {
+				continue
+			}
+			d := (sizeI*distances[bestI][k] + sizeJ*distances[bestJ][k]) / (sizeI + sizeJ)
+			distances[bestI][k], distances[k][bestI] = d, d
+		}
+		groups[bestI] = append(groups[bestI], groups[bestJ]...)
+		groups[bestJ] = nil
+	}
+
+	merged := make([][]int, 0, len(groups))
+	for _, group := range groups {
+		if group != nil {
+			merged = append(merged, group)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return len(merged[i]) > len(merged[j])
+	})
+	clusters := make([]*Cluster, 0, len(merged))
+	for i, group := range merged {
+		members := make([]string, 0, len(group))
+		for _, index := range group {
+			members = append(members, ids[index])
+		}
+		clusters = append(clusters, &Cluster{
+			ID:              i + 1,
+			Members:         members,
+			Representatives: representatives(group, ids, documentDistances),
+		})
+	}
+	return clusters
+}
+
+// representatives returns the members with the lowest average distance to the rest:
+func representatives(group []int, ids []string, distances [][]float64) []string {
+	scores := make(map[int]float64)
+	for _, x := range group {
+		for _, y := range group {
+			scores[x] += distances[x][y]
+		}
+	}
+	sorted := append([]int{}, group...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i]] < scores[sorted[j]]
+	})
+	if len(sorted) > maxRepresentatives {
+		sorted = sorted[:maxRepresentatives]
+	}
+	members := make([]string, 0, len(sorted))
+	for _, index := range sorted {
+		members = append(members, ids[index])
+	}
+	return members
+}
@@ -1,24 +1,127 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"os"
+	"regexp"
+	"time"
 )
 
 // Config is the main configuration struct:
 type Config struct {
-	PDFPath      string              `json:"pdf_path"`
-	ImagePath    string              `json:"image_path"`
-	JSONPath     string              `json:"json_path"`
-	StorePath    string              `json:"store_path"`
-	SamplesPath  string              `json:"samples_path"`
-	SampleData   map[string][]string `json:"sample_data"`
-	OpenAIConfig OpenAIConfig        `json:"openai"`
+	PDFPath     string              `json:"pdf_path"`
+	ImagePath   string              `json:"image_path"`
+	JSONPath    string              `json:"json_path"`
+	StorePath   string              `json:"store_path"`
+	SamplesPath string              `json:"samples_path"`
+	SampleData  map[string][]string `json:"sample_data"`
+	// DocumentTypes defines the known document types:
+	DocumentTypes []DocumentTypeConfig `json:"document_types"`
+	// PromptsPath is an optional directory with prompt templates overriding the embedded ones:
+	PromptsPath string `json:"prompts_path"`
+	// TemplatesPath is the directory with the layout templates used by the positional extractor:
+	TemplatesPath string       `json:"templates_path"`
+	OpenAIConfig  OpenAIConfig `json:"openai"`
+	// ExtractionConfig sets the extraction backends:
+	ExtractionConfig ExtractionConfig `json:"extraction"`
+	// ReviewConfig sets when documents need a human review:
+	ReviewConfig ReviewConfig `json:"review"`
+	// LegislativePeriods sets the chamber sizes used to validate extracted votes:
+	LegislativePeriods []LegislativePeriodConfig `json:"legislative_periods"`
+
+	// fileName is the file the config was loaded from:
+	fileName string
+}
+
+// DocumentTypeConfig is the configuration of a single document type:
+type DocumentTypeConfig struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Samples     []string `json:"samples"`
+	Hints       string   `json:"hints"`
+	Extractor   string   `json:"extractor"`
+	// Chamber is the chamber of documents of this type when the document doesn't state it:
+	Chamber string `json:"chamber"`
+	// Extractors are fallback extractors, tried in order when the previous one fails:
+	Extractors []string `json:"extractors"`
+	// Rules classify a document without calling the model when all of them match:
+	Rules []RuleConfig `json:"rules"`
+}
+
+// RuleConfig is a condition on a PDF metadata or text field
+// Every condition that is set must hold for the rule to match:
+type RuleConfig struct {
+	Field    string   `json:"field"`
+	Equals   string   `json:"equals,omitempty"`
+	Contains string   `json:"contains,omitempty"`
+	Regexp   string   `json:"regexp,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	After    string   `json:"after,omitempty"`
+	Before   string   `json:"before,omitempty"`
+}
+
+// LegislativePeriodConfig is a legislative period, dates use the YYYY-MM-DD format:
+type LegislativePeriodConfig struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Chambers maps the chamber name -"senadores" or "diputados"- to its amount of seats:
+	Chambers map[string]int `json:"chambers"`
+}
+
+// ReviewConfig is the review configuration struct:
+type ReviewConfig struct {
+	// MinConfidence is the field and vote confidence below which a document needs review, 0.8 by default:
+	MinConfidence float64 `json:"min_confidence"`
+}
+
+// ExtractionConfig is the extraction configuration struct:
+type ExtractionConfig struct {
+	// Backend is the vision backend used for scanned documents: "openai" -default- or "tesseract":
+	Backend string `json:"backend"`
+	// TesseractPath is the path to the tesseract binary:
+	TesseractPath string `json:"tesseract_path"`
+	// Language is the tesseract language:
+	Language string `json:"language"`
+	// Preprocess corrects the orientation and skew of scanned pages and binarizes them before OCR:
+	Preprocess bool `json:"preprocess"`
 }
 
 // OpenAIConfig is the OpenAI configuration struct:
 type OpenAIConfig struct {
 	Token string `json:"token"`
+	// CachePath is the directory where completion responses are cached:
+	CachePath string `json:"cache_path"`
+	// CacheTTL is the max age of a cached response -e.g. "720h"-, empty means no expiration:
+	CacheTTL string `json:"cache_ttl"`
+	// DisableCache skips cache lookups, fresh responses are still written to it:
+	DisableCache bool `json:"disable_cache"`
+	// Pricing overrides the USD price per 1K tokens for a given model:
+	Pricing map[string]ModelPricing `json:"pricing"`
+	// ResponseFormat enables the JSON mode -"json_object"- or structured outputs -"json_schema"-
+	// for models that support them, by default only the prompt describes the expected output:
+	ResponseFormat string `json:"response_format"`
+	// MaxAttempts is the amount of times an invalid structured output is re-asked:
+	MaxAttempts int `json:"max_attempts"`
+	// MaxCost is the spend cap in USD for a single run, zero means no cap:
+	MaxCost float64 `json:"max_cost"`
+}
+
+// ModelPricing is the USD price per 1K tokens for a model:
+type ModelPricing struct {
+	PromptPer1K     float64 `json:"prompt_per_1k"`
+	CompletionPer1K float64 `json:"completion_per_1k"`
+}
+
+// GetCacheTTL parses the cache TTL:
+func (c OpenAIConfig) GetCacheTTL() (time.Duration, error) {
+	if c.CacheTTL == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.CacheTTL)
 }
 
 // Load takes a file, parses it and returns a config:
@@ -31,5 +134,87 @@ func Load(fileName string) (*Config, error) {
 	if err := json.Unmarshal(contents, &cfg); err != nil {
 		return nil, err
 	}
+	cfg.fileName = fileName
 	return &cfg, nil
 }
+
+// keyIndentRe matches the indentation of the first key of an indented file:
+var keyIndentRe = regexp.MustCompile(`\n([ \t]+)"`)
+
+// SaveSampleData writes the current sample data back to the config file
+// Only the "sample_data" value is replaced, the rest of the file is kept as is since
+// other values might have been set to their defaults during initialization:
+func (c *Config) SaveSampleData() error {
+	if c.fileName == "" {
+		return errors.New("config wasn't loaded from a file")
+	}
+	contents, err := os.ReadFile(c.fileName)
+	if err != nil {
+		return err
+	}
+	start, end, err := sampleDataOffsets(contents)
+	if err != nil {
+		return err
+	}
+	// Single line files stay in a single line, otherwise the value is indented like the other keys:
+	compact := !bytes.Contains(bytes.TrimSpace(contents), []byte("\n"))
+	indent := "  "
+	if match := keyIndentRe.FindSubmatch(contents); match != nil {
+		indent = string(match[1])
+	}
+	var rawSampleData []byte
+	if compact {
+		rawSampleData, err = json.Marshal(c.SampleData)
+	} else {
+		rawSampleData, err = json.MarshalIndent(c.SampleData, indent, indent)
+	}
+	if err != nil {
+		return err
+	}
+	if start == end {
+		// The key is missing, it's added as the last one:
+		var separator string
+		if len(bytes.TrimSpace(contents[bytes.IndexByte(contents, '{')+1:start])) > 0 {
+			separator = ","
+			if compact {
+				separator += " "
+			}
+		}
+		if !compact {
+			separator += "\n" + indent
+		}
+		rawSampleData = append([]byte(separator+`"sample_data": `), rawSampleData...)
+	}
+	updated := make([]byte, 0, len(contents)+len(rawSampleData))
+	updated = append(updated, contents[:start]...)
+	updated = append(updated, rawSampleData...)
+	updated = append(updated, contents[end:]...)
+	return os.WriteFile(c.fileName, updated, 0644)
+}
+
+// sampleDataOffsets returns the byte range of the "sample_data" value in a config file
+// When the key is missing both offsets point to the closing brace of the object:
+func sampleDataOffsets(contents []byte) (int, int, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return 0, 0, errors.New("config file isn't a JSON object")
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, 0, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return 0, 0, err
+		}
+		if token == "sample_data" {
+			end := int(decoder.InputOffset())
+			return end - len(value), end, nil
+		}
+	}
+	end := bytes.LastIndexByte(contents, '}')
+	// Keep the whitespace before the closing brace after the added key:
+	end = len(bytes.TrimRight(contents[:end], " \t\r\n"))
+	return end, end, nil
+}
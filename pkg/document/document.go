@@ -3,8 +3,11 @@ package document
 import (
 	"encoding/base64"
 	"os"
+	"time"
 
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/pdfmeta"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
 )
 
 // Document is the main document struct:
@@ -21,13 +24,200 @@ type Document struct {
 	JSONPath string `json:"json_path"`
 	// Type is the document type -set during the classification step-:
 	Type types.DocumentType `json:"type"`
+	// Metadata holds the PDF properties recorded on ingestion:
+	Metadata *pdfmeta.Metadata `json:"metadata,omitempty"`
+	// Classification records how the document type was obtained:
+	Classification *ResultInfo `json:"classification,omitempty"`
+	// Votes is the extracted vote record -set during the extraction step-:
+	Votes *vote.Record `json:"votes,omitempty"`
+	// Extraction records how the votes were extracted:
+	Extraction *ResultInfo `json:"extraction,omitempty"`
+	// FieldConfidence holds the extraction confidence per field:
+	FieldConfidence map[string]float64 `json:"field_confidence,omitempty"`
+	// Evidence holds the raw data the extracted votes are based on:
+	Evidence []*Evidence `json:"evidence,omitempty"`
+	// Extractions holds the result of every extractor when using the ensemble mode:
+	Extractions []*ExtractionResult `json:"extractions,omitempty"`
+	// Comparison is the comparison of the ensemble results:
+	Comparison *Comparison `json:"comparison,omitempty"`
+	// Validation holds the result of the last validation of the extracted votes:
+	Validation *Validation `json:"validation,omitempty"`
+	// ProcessedImages are the corrected and binarized images of scanned pages,
+	// it's empty for preprocessed documents without scanned pages and null when they weren't preprocessed:
+	ProcessedImages []*ProcessedImage `json:"processed_images"`
+	// UnresolvedNames are the vote names that couldn't be matched to a legislator:
+	UnresolvedNames []*UnresolvedName `json:"unresolved_names,omitempty"`
+	// Reviews are the corrections made by reviewers, oldest first:
+	Reviews []*Review `json:"reviews,omitempty"`
+}
+
+// UnresolvedName is a vote name without a reliable legislator match:
+type UnresolvedName struct {
+	Name string `json:"name"`
+	// Candidates are the IDs of the most similar legislators, best first:
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// Review is a correction made by a reviewer:
+type Review struct {
+	By string    `json:"by"`
+	At time.Time `json:"at"`
+	// Changes are the modified fields, empty when the reviewer accepted the document as is:
+	Changes []*Change `json:"changes,omitempty"`
+}
+
+// Change is a field modified during a review:
+type Change struct {
+	// Field is the changed field -e.g. "type", "date", "totals.si"- or "vote" for individual votes:
+	Field string `json:"field"`
+	// Name is the legislator name of vote changes:
+	Name string `json:"name,omitempty"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// Comparison is the field by field comparison of the results of an ensemble extraction:
+type Comparison struct {
+	// Extractors are the compared extractors, the first one provides the stored votes:
+	Extractors []string `json:"extractors"`
+	// Failures maps the extractors that failed to their error:
+	Failures map[string]string `json:"failures,omitempty"`
+	// Disagreements are the fields and legislators where the results differ:
+	Disagreements []*Disagreement `json:"disagreements,omitempty"`
+	// Accepted is set when the results agree or a reviewer resolved the disagreements:
+	Accepted bool `json:"accepted"`
+}
+
+// Disagreement is a field or individual vote where the extractors differ:
+type Disagreement struct {
+	// Field is the record field -e.g. "date", "totals.si"- or "vote" for individual votes:
+	Field string `json:"field"`
+	// Name is the legislator name of vote disagreements:
+	Name string `json:"name,omitempty"`
+	// Values maps each extractor to its value, empty when it didn't extract it:
+	Values map[string]string `json:"values"`
+}
+
+// Validation is the result of checking the extracted votes:
+type Validation struct {
+	Errors    []*ValidationError `json:"errors"`
+	CheckedAt time.Time          `json:"checked_at"`
+	// Override is set when the document is accepted for export despite its errors:
+	Override *ValidationOverride `json:"override,omitempty"`
+}
+
+// ValidationError is a failed check:
+type ValidationError struct {
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// ValidationOverride records who accepted a failing document and why:
+type ValidationOverride struct {
+	By     string    `json:"by"`
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// Passed reports if every check passed:
+func (v *Validation) Passed() bool {
+	return len(v.Errors) == 0
+}
+
+// Exportable reports if the document can be exported: its votes were validated
+// and either passed every check or were accepted with an override, ensemble
+// disagreements must be resolved too:
+func (d *Document) Exportable() bool {
+	if d.Votes == nil || d.Validation == nil {
+		return false
+	}
+	if d.Comparison != nil && !d.Comparison.Accepted {
+		return false
+	}
+	return d.Validation.Passed() || d.Validation.Override != nil
+}
+
+// VoteChamber returns the chamber of the votes, the chamber of the document type
+// is used when the record doesn't state it:
+func (d *Document) VoteChamber(registry *types.Registry) string {
+	if d.Votes != nil && d.Votes.Chamber != "" {
+		return d.Votes.Chamber
+	}
+	if definition := registry.Get(d.Type); definition != nil {
+		return definition.Chamber
+	}
+	return ""
+}
+
+// ProcessedImage is the preprocessed image of a page, the original is kept in ImagePaths:
+type ProcessedImage struct {
+	// Page is the page index:
+	Page      int    `json:"page"`
+	ImagePath string `json:"image_path"`
+	// Rotation and Skew are the corrections applied, in degrees:
+	Rotation int     `json:"rotation"`
+	Skew     float64 `json:"skew"`
+}
+
+// ExtractionResult is the output of an extractor:
+type ExtractionResult struct {
+	// Votes is the extracted vote record:
+	Votes *vote.Record `json:"votes"`
+	// Confidence holds a confidence score between 0 and 1 per field:
+	Confidence map[string]float64 `json:"confidence,omitempty"`
+	// Evidence holds the raw data the result is based on:
+	Evidence []*Evidence `json:"evidence,omitempty"`
+	// Info records what produced the result:
+	Info *ResultInfo `json:"info"`
+}
+
+// Evidence is a piece of raw data used by an extractor:
+type Evidence struct {
+	// Field is the field the evidence refers to -e.g. "votes", "date"-:
+	Field string `json:"field"`
+	// Page is the page index:
+	Page int `json:"page"`
+	// Source is where the data comes from: "text", "ocr" or "llm":
+	Source string `json:"source"`
+	// Raw is the raw text or model output:
+	Raw string `json:"raw"`
+}
+
+// ResultInfo records what produced a classification or extraction result:
+type ResultInfo struct {
+	// Method is the strategy that produced the result -e.g. "llm"-:
+	Method string `json:"method"`
+	// Extractor is the name of the extractor, only set for extraction results:
+	Extractor string `json:"extractor,omitempty"`
+	// Model is the model used, if any:
+	Model string `json:"model,omitempty"`
+	// Prompt is the prompt template name, if any:
+	Prompt string `json:"prompt,omitempty"`
+	// PromptVersion is the version of the prompt template:
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// CreatedAt is the time when the result was produced:
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // ImageAsBase64 returns the first image as a base64 string
-// TODO: support page selection or implement an alternative method for it
+// Use ImageFileAsBase64 for other pages:
 func (d *Document) ImageAsBase64() (string, error) {
 	// Pick the first page:
-	image := d.ImagePaths[0]
+	return ImageFileAsBase64(d.ImagePaths[0])
+}
+
+// ProcessedImagePath returns the preprocessed image of a page, empty when there's none:
+func (d *Document) ProcessedImagePath(page int) string {
+	for _, image := range d.ProcessedImages {
+		if image.Page == page {
+			return image.ImagePath
+		}
+	}
+	return ""
+}
+
+// ImageFileAsBase64 returns an image file as a base64 string:
+func ImageFileAsBase64(image string) (string, error) {
 	imageData, err := os.ReadFile(image)
 	if err != nil {
 		return "", err
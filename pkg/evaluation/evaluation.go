@@ -0,0 +1,268 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/usage"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// GroundTruth is the hand labeled data for a single PDF
+// It's read from a JSON file with the same name as the PDF:
+type GroundTruth struct {
+	// Type is the expected document type:
+	Type string `json:"type"`
+	// Votes is the expected vote record, optional:
+	Votes *vote.Record `json:"votes,omitempty"`
+}
+
+// Case is the result of running the pipeline over a labeled PDF:
+type Case struct {
+	ID            string       `json:"id"`
+	PDFPath       string       `json:"pdf_path"`
+	Expected      *GroundTruth `json:"expected"`
+	PredictedType string       `json:"predicted_type"`
+	Extracted     *vote.Record `json:"extracted,omitempty"`
+	Error         string       `json:"error,omitempty"`
+}
+
+// LoadGroundTruth loads the ground truth of every PDF in a directory
+// PDFs without a matching JSON file are skipped:
+func LoadGroundTruth(dir string) ([]*Case, error) {
+	cases := make([]*Case, 0)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pdf" {
+			continue
+		}
+		pdfPath := filepath.Join(dir, entry.Name())
+		rawTruth, err := os.ReadFile(strings.TrimSuffix(pdfPath, ".pdf") + ".json")
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var truth GroundTruth
+		if err := json.Unmarshal(rawTruth, &truth); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		cases = append(cases, &Case{
+			ID:       entry.Name(),
+			PDFPath:  pdfPath,
+			Expected: &truth,
+		})
+	}
+	return cases, nil
+}
+
+// FieldScore holds the counts used for precision and recall of a field:
+type FieldScore struct {
+	TruePositives  int `json:"true_positives"`
+	FalsePositives int `json:"false_positives"`
+	FalseNegatives int `json:"false_negatives"`
+}
+
+// Precision returns the precision of the field:
+func (f *FieldScore) Precision() float64 {
+	return ratio(f.TruePositives, f.TruePositives+f.FalsePositives)
+}
+
+// Recall returns the recall of the field:
+func (f *FieldScore) Recall() float64 {
+	return ratio(f.TruePositives, f.TruePositives+f.FalseNegatives)
+}
+
+// add scores a single extracted value against the expected one:
+func (f *FieldScore) add(expected, extracted string) {
+	switch {
+	case extracted != "" && extracted == expected:
+		f.TruePositives++
+	case extracted != "" && expected == "":
+		f.FalsePositives++
+	case extracted != "":
+		f.FalsePositives++
+		f.FalseNegatives++
+	case expected != "":
+		f.FalseNegatives++
+	}
+}
+
+// Report is the evaluation report:
+type Report struct {
+	Cases []*Case `json:"cases"`
+	// Labels is the sorted list of labels in the confusion matrix:
+	Labels []string `json:"labels"`
+	// Confusion maps expected type -> predicted type -> count:
+	Confusion map[string]map[string]int `json:"confusion"`
+	// ClassificationAccuracy is the ratio of correctly classified documents:
+	ClassificationAccuracy float64 `json:"classification_accuracy"`
+	// Fields holds the precision/recall counts per extracted field:
+	Fields map[string]*FieldScore `json:"fields"`
+	// VotesCorrect and VotesExpected are used for the per-legislator vote accuracy:
+	VotesCorrect  int `json:"votes_correct"`
+	VotesExpected int `json:"votes_expected"`
+	// Usage is the token usage and cost of the evaluation run:
+	Usage *usage.Summary `json:"usage"`
+}
+
+// VoteAccuracy returns the ratio of correctly extracted legislator votes:
+func (r *Report) VoteAccuracy() float64 {
+	return ratio(r.VotesCorrect, r.VotesExpected)
+}
+
+// Evaluate computes the report for the given cases:
+func Evaluate(cases []*Case, usageSummary *usage.Summary) *Report {
+	r := &Report{
+		Cases:     cases,
+		Confusion: make(map[string]map[string]int),
+		Fields:    make(map[string]*FieldScore),
+		Usage:     usageSummary,
+	}
+	labels := make(map[string]bool)
+	correct := 0
+	for _, c := range cases {
+		expectedType, predictedType := c.Expected.Type, c.PredictedType
+		if predictedType == "" {
+			predictedType = "-"
+		}
+		labels[expectedType] = true
+		labels[predictedType] = true
+		if r.Confusion[expectedType] == nil {
+			r.Confusion[expectedType] = make(map[string]int)
+		}
+		r.Confusion[expectedType][predictedType]++
+		if expectedType == predictedType {
+			correct++
+		}
+		if c.Expected.Votes != nil {
+			r.scoreRecord(c.Expected.Votes, c.Extracted)
+		}
+	}
+	for label := range labels {
+		r.Labels = append(r.Labels, label)
+	}
+	sort.Strings(r.Labels)
+	r.ClassificationAccuracy = ratio(correct, len(cases))
+	return r
+}
+
+// scoreRecord compares an extracted record field by field, a nil extracted record counts as empty:
+func (r *Report) scoreRecord(expected, extracted *vote.Record) {
+	if extracted == nil {
+		extracted = &vote.Record{}
+	}
+	r.field("chamber").add(expected.Chamber, extracted.Chamber)
+	r.field("date").add(expected.Date, extracted.Date)
+	r.field("time").add(expected.Time, extracted.Time)
+	r.field("subject").add(normalizeText(expected.Subject), normalizeText(extracted.Subject))
+	options := make(map[vote.Option]bool)
+	for option := range expected.Totals {
+		options[option] = true
+	}
+	for option := range extracted.Totals {
+		options[option] = true
+	}
+	for option := range options {
+		r.field("totals."+string(option)).add(count(expected.Totals, option), count(extracted.Totals, option))
+	}
+	for _, expectedVote := range expected.Votes {
+		r.VotesExpected++
+		extractedVote := extracted.VoteByName(expectedVote.Name)
+		extractedOption := ""
+		if extractedVote != nil {
+			extractedOption = string(extractedVote.Option)
+		}
+		if extractedOption == string(expectedVote.Option) {
+			r.VotesCorrect++
+		}
+		r.field("votes").add(string(expectedVote.Option), extractedOption)
+	}
+	// Votes for legislators that aren't in the ground truth are false positives:
+	for _, extractedVote := range extracted.Votes {
+		if expected.VoteByName(extractedVote.Name) == nil {
+			r.field("votes").add("", string(extractedVote.Option))
+		}
+	}
+}
+
+func (r *Report) field(name string) *FieldScore {
+	if r.Fields[name] == nil {
+		r.Fields[name] = &FieldScore{}
+	}
+	return r.Fields[name]
+}
+
+// Print writes a human readable version of the report:
+func (r *Report) Print(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Documents: %d\tClassification accuracy: %.2f\n\n", len(r.Cases), r.ClassificationAccuracy)
+	fmt.Fprint(w, "expected \\ predicted")
+	for _, label := range r.Labels {
+		fmt.Fprintf(w, "\t%s", label)
+	}
+	fmt.Fprintln(w)
+	for _, expected := range r.Labels {
+		if r.Confusion[expected] == nil {
+			continue
+		}
+		fmt.Fprint(w, expected)
+		for _, predicted := range r.Labels {
+			fmt.Fprintf(w, "\t%d", r.Confusion[expected][predicted])
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+
+	fieldNames := make([]string, 0, len(r.Fields))
+	for name := range r.Fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+	if len(fieldNames) > 0 {
+		fmt.Fprintln(w, "field\tprecision\trecall")
+		for _, name := range fieldNames {
+			f := r.Fields[name]
+			fmt.Fprintf(w, "%s\t%.2f\t%.2f\n", name, f.Precision(), f.Recall())
+		}
+		fmt.Fprintf(w, "\nVote accuracy: %.2f (%d/%d)\n", r.VoteAccuracy(), r.VotesCorrect, r.VotesExpected)
+	}
+	for _, c := range r.Cases {
+		if c.Error != "" {
+			fmt.Fprintf(w, "error in %s: %s\n", c.ID, c.Error)
+		}
+	}
+	if r.Usage != nil {
+		fmt.Fprintf(w, "\nUsage: %s\n", &r.Usage.Totals)
+	}
+	w.Flush()
+}
+
+func count(totals map[vote.Option]int, option vote.Option) string {
+	n, ok := totals[option]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(n)
+}
+
+func normalizeText(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
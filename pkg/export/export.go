@@ -0,0 +1,124 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/legislator"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/store"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/rs/zerolog"
+)
+
+// Exporter serializes the extracted votes
+// Only exportable documents are included, see document.Document.Exportable:
+type Exporter struct {
+	store  *store.Store
+	types  *types.Registry
+	logger zerolog.Logger
+}
+
+// dateLayout is the layout of the date filters:
+const dateLayout = "2006-01-02"
+
+var errBadFilter = errors.New("invalid filter")
+
+// Filter restricts the exported documents, empty fields match every document:
+type Filter struct {
+	// From and To are inclusive YYYY-MM-DD dates:
+	From string
+	To   string
+	// Chamber matches the vote chamber, see document.Document.VoteChamber:
+	Chamber string
+	Type    types.DocumentType
+}
+
+// Validate checks the filter dates:
+func (f *Filter) Validate() error {
+	for _, date := range []string{f.From, f.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("%w: date %q, the format is YYYY-MM-DD", errBadFilter, date)
+		}
+	}
+	if f.From != "" && f.To != "" && f.To < f.From {
+		return fmt.Errorf("%w: %s is before %s", errBadFilter, f.To, f.From)
+	}
+	return nil
+}
+
+// match reports if a document passes the filter, a nil filter matches everything:
+func (f *Filter) match(d *document.Document, registry *types.Registry) bool {
+	if f == nil {
+		return true
+	}
+	if f.From != "" && d.Votes.Date < f.From || f.To != "" && d.Votes.Date > f.To {
+		return false
+	}
+	if f.Type != "" && d.Type != f.Type {
+		return false
+	}
+	return f.Chamber == "" || d.VoteChamber(registry) == f.Chamber
+}
+
+// documents returns the exportable documents that pass the filter sorted by date, time and ID:
+func (e *Exporter) documents(filter *Filter) []*document.Document {
+	docs := make([]*document.Document, 0)
+	skipped := 0
+	for _, d := range e.store.RetrieveDocuments() {
+		if d.Votes == nil || !filter.match(d, e.types) {
+			continue
+		}
+		if !d.Exportable() {
+			skipped++
+			continue
+		}
+		docs = append(docs, d)
+	}
+	if skipped > 0 {
+		e.logger.Warn().Msgf("skipping %d documents that didn't pass validation, see the validar command", skipped)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		a, b := docs[i].Votes, docs[j].Votes
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		return docs[i].ID < docs[j].ID
+	})
+	return docs
+}
+
+// persons returns the registry indexed by ID:
+func (e *Exporter) persons() map[string]*legislator.Person {
+	persons := make(map[string]*legislator.Person)
+	for _, p := range e.store.RetrieveLegislators() {
+		persons[p.ID] = p
+	}
+	return persons
+}
+
+// voterID returns the legislator ID of a vote, names that weren't resolved to the
+// registry get an ID derived from the name, as in the API:
+func voterID(persons map[string]*legislator.Person, legislatorID, name string) string {
+	if _, ok := persons[legislatorID]; ok {
+		return legislatorID
+	}
+	return legislator.Slug(name)
+}
+
+// New initializes an exporter:
+func New(store *store.Store, registry *types.Registry, logger zerolog.Logger) *Exporter {
+	return &Exporter{
+		store:  store,
+		types:  registry,
+		logger: logger,
+	}
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/legislator"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// popoloOptions maps the vote options to the Popolo ones, other options -like motions- are kept:
+var popoloOptions = map[vote.Option]string{
+	vote.Yes:        "yes",
+	vote.No:         "no",
+	vote.Abstention: "abstain",
+	vote.Absent:     "absent",
+	vote.NotVoting:  "not voting",
+}
+
+// Popolo is a Popolo JSON document, see https://www.popoloproject.com/specs/:
+type Popolo struct {
+	Persons       []*PopoloPerson       `json:"persons"`
+	Organizations []*PopoloOrganization `json:"organizations"`
+	Memberships   []*PopoloMembership   `json:"memberships"`
+	VoteEvents    []*PopoloVoteEvent    `json:"vote_events"`
+}
+
+// PopoloPerson is a Popolo person:
+type PopoloPerson struct {
+	ID         string             `json:"id"`
+	Name       string             `json:"name"`
+	OtherNames []*PopoloOtherName `json:"other_names,omitempty"`
+}
+
+// PopoloOtherName is an alternate name of a person:
+type PopoloOtherName struct {
+	Name string `json:"name"`
+}
+
+// PopoloOrganization is a Popolo organization: a chamber, party or bloc:
+type PopoloOrganization struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Classification string `json:"classification"`
+}
+
+// PopoloMembership is a Popolo membership:
+type PopoloMembership struct {
+	PersonID       string `json:"person_id"`
+	OrganizationID string `json:"organization_id"`
+	Role           string `json:"role,omitempty"`
+	StartDate      string `json:"start_date,omitempty"`
+	EndDate        string `json:"end_date,omitempty"`
+}
+
+// PopoloVoteEvent is a Popolo vote event:
+type PopoloVoteEvent struct {
+	ID             string          `json:"id"`
+	OrganizationID string          `json:"organization_id,omitempty"`
+	StartDate      string          `json:"start_date"`
+	Motion         *PopoloMotion   `json:"motion,omitempty"`
+	Counts         []*PopoloCount  `json:"counts"`
+	Votes          []*PopoloVote   `json:"votes"`
+	Sources        []*PopoloSource `json:"sources,omitempty"`
+}
+
+// PopoloMotion is the motion voted:
+type PopoloMotion struct {
+	Text string `json:"text"`
+}
+
+// PopoloCount is the amount of votes of an option:
+type PopoloCount struct {
+	Option string `json:"option"`
+	Value  int    `json:"value"`
+}
+
+// PopoloVote is an individual vote, GroupID is the party at the vote date:
+type PopoloVote struct {
+	VoterID string `json:"voter_id"`
+	Option  string `json:"option"`
+	GroupID string `json:"group_id,omitempty"`
+}
+
+// PopoloSource is where the data comes from:
+type PopoloSource struct {
+	URL  string `json:"url"`
+	Note string `json:"note,omitempty"`
+}
+
+// popoloOption returns the Popolo option of a vote option:
+func popoloOption(option vote.Option) string {
+	if mapped, ok := popoloOptions[option]; ok {
+		return mapped
+	}
+	return string(option)
+}
+
+// BuildPopolo builds the Popolo document from the exportable votes, the legislator
+// registry and the memberships. Chambers are included as organizations:
+func (e *Exporter) BuildPopolo(filter *Filter) *Popolo {
+	out := &Popolo{
+		Persons:       make([]*PopoloPerson, 0),
+		Organizations: make([]*PopoloOrganization, 0),
+		Memberships:   make([]*PopoloMembership, 0),
+		VoteEvents:    make([]*PopoloVoteEvent, 0),
+	}
+	persons := e.persons()
+	organizations := make(map[string]*PopoloOrganization)
+	addOrganization := func(name, classification string) string {
+		id := legislator.OrganizationID(classification, name)
+		if _, ok := organizations[id]; !ok {
+			organizations[id] = &PopoloOrganization{ID: id, Name: name, Classification: classification}
+		}
+		return id
+	}
+	unregistered := make(map[string]*PopoloPerson)
+	for _, d := range e.documents(filter) {
+		event := &PopoloVoteEvent{
+			ID:        d.ID,
+			StartDate: d.Votes.Date,
+			Counts:    make([]*PopoloCount, 0),
+			Votes:     make([]*PopoloVote, 0, len(d.Votes.Votes)),
+		}
+		if d.Votes.Time != "" {
+			event.StartDate += "T" + d.Votes.Time
+		}
+		if chamber := d.VoteChamber(e.types); chamber != "" {
+			event.OrganizationID = addOrganization(chamber, "chamber")
+		}
+		if d.Votes.Subject != "" {
+			event.Motion = &PopoloMotion{Text: d.Votes.Subject}
+		}
+		if d.SourceURL != "" {
+			event.Sources = append(event.Sources, &PopoloSource{URL: d.SourceURL, Note: d.ID})
+		}
+		// The printed totals are preferred over the counted votes:
+		counts := d.Votes.Totals
+		if len(counts) == 0 {
+			counts = d.Votes.Count()
+		}
+		options := make([]string, 0, len(counts))
+		for option := range counts {
+			options = append(options, string(option))
+		}
+		sort.Strings(options)
+		for _, option := range options {
+			event.Counts = append(event.Counts, &PopoloCount{Option: popoloOption(vote.Option(option)), Value: counts[vote.Option(option)]})
+		}
+		for _, v := range d.Votes.Votes {
+			id := voterID(persons, v.LegislatorID, v.Name)
+			if _, ok := persons[id]; !ok {
+				if _, ok := unregistered[id]; !ok {
+					unregistered[id] = &PopoloPerson{ID: id, Name: v.Name}
+				}
+			}
+			popoloVote := &PopoloVote{VoterID: id, Option: popoloOption(v.Option)}
+			if v.Party != "" {
+				popoloVote.GroupID = addOrganization(v.Party, legislator.ClassificationParty)
+			}
+			event.Votes = append(event.Votes, popoloVote)
+		}
+		out.VoteEvents = append(out.VoteEvents, event)
+	}
+	for _, p := range e.store.RetrieveLegislators() {
+		person := &PopoloPerson{ID: p.ID, Name: p.Name}
+		for _, alias := range p.Aliases {
+			person.OtherNames = append(person.OtherNames, &PopoloOtherName{Name: alias})
+		}
+		out.Persons = append(out.Persons, person)
+	}
+	for _, person := range unregistered {
+		out.Persons = append(out.Persons, person)
+	}
+	sort.Slice(out.Persons, func(i, j int) bool {
+		return out.Persons[i].ID < out.Persons[j].ID
+	})
+	for _, m := range e.store.RetrieveMemberships() {
+		out.Memberships = append(out.Memberships, &PopoloMembership{
+			PersonID:       m.PersonID,
+			OrganizationID: addOrganization(m.Organization, m.Classification),
+			Role:           m.Role,
+			StartDate:      m.Start,
+			EndDate:        m.End,
+		})
+	}
+	for _, organization := range organizations {
+		out.Organizations = append(out.Organizations, organization)
+	}
+	sort.Slice(out.Organizations, func(i, j int) bool {
+		return out.Organizations[i].ID < out.Organizations[j].ID
+	})
+	return out
+}
+
+// WritePopolo writes the Popolo document as indented JSON:
+func (e *Exporter) WritePopolo(w io.Writer, filter *Filter) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e.BuildPopolo(filter))
+}
@@ -0,0 +1,247 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/parquet"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// Table names, also used as file names:
+const (
+	// TableVotes has a row per legislator per vote:
+	TableVotes = "votes"
+	// TableVoteEvents has a row per vote with its counts:
+	TableVoteEvents = "vote_events"
+)
+
+// Table is a flat table, row values are bool, int, float64 or string according to the column type:
+type Table struct {
+	Name    string
+	Columns []*parquet.Column
+	Rows    [][]interface{}
+}
+
+// TableWriter writes a table in a file format:
+type TableWriter func(w io.Writer, table *Table) error
+
+// provenanceColumns describe the vote and where it comes from, they start the rows of both tables:
+var provenanceColumns = []*parquet.Column{
+	{Name: "document_id", Type: parquet.String},
+	{Name: "date", Type: parquet.String},
+	{Name: "time", Type: parquet.String},
+	{Name: "chamber", Type: parquet.String},
+	{Name: "subject", Type: parquet.String},
+	{Name: "document_type", Type: parquet.String},
+	{Name: "source_url", Type: parquet.String},
+	{Name: "pdf_path", Type: parquet.String},
+	{Name: "extraction_method", Type: parquet.String},
+	{Name: "extractor", Type: parquet.String},
+	{Name: "reviewed", Type: parquet.Boolean},
+	{Name: "validation_override", Type: parquet.Boolean},
+}
+
+// voteColumns follow the provenance columns in the votes table:
+var voteColumns = []*parquet.Column{
+	{Name: "legislator_id", Type: parquet.String},
+	{Name: "registered", Type: parquet.Boolean},
+	{Name: "name", Type: parquet.String},
+	{Name: "party", Type: parquet.String},
+	{Name: "bloc", Type: parquet.String},
+	{Name: "option", Type: parquet.String},
+	{Name: "confidence", Type: parquet.Double},
+}
+
+// countOptions are the options with their own count column in the vote events table:
+var countOptions = []struct {
+	option vote.Option
+	column string
+}{
+	{vote.Yes, "yes"},
+	{vote.No, "no"},
+	{vote.Abstention, "abstention"},
+	{vote.Absent, "absent"},
+	{vote.NotVoting, "not_voting"},
+}
+
+// eventColumns follow the provenance columns in the vote events table, counts come from the
+// individual votes. "printed_totals" reports if the document has printed totals and "totals_match"
+// if they are equal to the counts:
+func eventColumns() []*parquet.Column {
+	columns := []*parquet.Column{{Name: "votes", Type: parquet.Int64}}
+	for _, count := range countOptions {
+		columns = append(columns, &parquet.Column{Name: count.column, Type: parquet.Int64})
+	}
+	return append(columns,
+		&parquet.Column{Name: "other", Type: parquet.Int64},
+		&parquet.Column{Name: "printed_totals", Type: parquet.Boolean},
+		&parquet.Column{Name: "totals_match", Type: parquet.Boolean},
+	)
+}
+
+// provenance returns the provenance values of a document:
+func (e *Exporter) provenance(d *document.Document) []interface{} {
+	var method, extractor string
+	if d.Extraction != nil {
+		method, extractor = d.Extraction.Method, d.Extraction.Extractor
+	}
+	return []interface{}{
+		d.ID,
+		d.Votes.Date,
+		d.Votes.Time,
+		d.VoteChamber(e.types),
+		d.Votes.Subject,
+		string(d.Type),
+		d.SourceURL,
+		d.PDFPath,
+		method,
+		extractor,
+		len(d.Reviews) > 0,
+		d.Validation != nil && d.Validation.Override != nil,
+	}
+}
+
+// BuildTables builds the votes and vote events tables from the exportable votes:
+func (e *Exporter) BuildTables(filter *Filter) []*Table {
+	votes := &Table{
+		Name:    TableVotes,
+		Columns: append(append([]*parquet.Column{}, provenanceColumns...), voteColumns...),
+		Rows:    make([][]interface{}, 0),
+	}
+	events := &Table{
+		Name:    TableVoteEvents,
+		Columns: append(append([]*parquet.Column{}, provenanceColumns...), eventColumns()...),
+		Rows:    make([][]interface{}, 0),
+	}
+	persons := e.persons()
+	for _, d := range e.documents(filter) {
+		provenance := e.provenance(d)
+		for _, v := range d.Votes.Votes {
+			id := voterID(persons, v.LegislatorID, v.Name)
+			_, registered := persons[id]
+			row := append(append(make([]interface{}, 0, len(votes.Columns)), provenance...),
+				id, registered, v.Name, v.Party, v.Bloc, string(v.Option), v.Confidence)
+			votes.Rows = append(votes.Rows, row)
+		}
+		counts := d.Votes.Count()
+		row := append(make([]interface{}, 0, len(events.Columns)), provenance...)
+		row = append(row, len(d.Votes.Votes))
+		other := len(d.Votes.Votes)
+		for _, count := range countOptions {
+			row = append(row, counts[count.option])
+			other -= counts[count.option]
+		}
+		match := len(d.Votes.Totals) > 0
+		for option, total := range d.Votes.Totals {
+			if counts[option] != total {
+				match = false
+			}
+		}
+		row = append(row, other, len(d.Votes.Totals) > 0, match)
+		events.Rows = append(events.Rows, row)
+	}
+	return []*Table{votes, events}
+}
+
+// WriteTables writes every table to a file named after it in dir, returns the written paths:
+func (e *Exporter) WriteTables(dir, extension string, write TableWriter, filter *Filter) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0)
+	for _, table := range e.BuildTables(filter) {
+		path := filepath.Join(dir, table.Name+extension)
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := write(f, table); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error writing %s: %w", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return nil, err
+		}
+		e.logger.Info().Msgf("%d rows written to %s", len(table.Rows), path)
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// WriteCSV writes a table as CSV with a header row:
+func WriteCSV(w io.Writer, table *Table) error {
+	writer := csv.NewWriter(w)
+	header := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		header = append(header, column.Name)
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i, value := range row {
+			switch v := value.(type) {
+			case string:
+				record[i] = v
+			case int:
+				record[i] = strconv.Itoa(v)
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				record[i] = strconv.FormatBool(v)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteNDJSON writes a table as newline delimited JSON, a JSON object per row with the keys
+// in the column order:
+func WriteNDJSON(w io.Writer, table *Table) error {
+	out := bufio.NewWriter(w)
+	keys := make([][]byte, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		key, err := json.Marshal(column.Name)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	for _, row := range table.Rows {
+		out.WriteByte('{')
+		for i, value := range row {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			out.Write(keys[i])
+			out.WriteByte(':')
+			out.Write(encoded)
+		}
+		out.WriteString("}\n")
+	}
+	return out.Flush()
+}
+
+// WriteParquet writes a table as a Parquet file:
+func WriteParquet(w io.Writer, table *Table) error {
+	return parquet.Write(w, table.Columns, table.Rows)
+}
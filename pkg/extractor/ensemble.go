@@ -0,0 +1,168 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/usage"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// ErrNoEnsemble is returned when a document type has less than two extractors to compare:
+var ErrNoEnsemble = errors.New("document type needs at least two extractors for an ensemble")
+
+// FieldVote is the disagreement field of individual votes:
+const FieldVote = "vote"
+
+// Ensemble runs every extractor in the chain of the document type independently and compares
+// their results. The results are returned in chain order, failed extractors are recorded in the
+// comparison and an error is only returned when all of them fail:
+func (r *Registry) Ensemble(in *Input) ([]*document.ExtractionResult, *document.Comparison, error) {
+	chain := r.Chain(in.Document.Type)
+	if len(chain) < 2 {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoEnsemble, in.Document.Type)
+	}
+	results := make([]*document.ExtractionResult, 0, len(chain))
+	failures := make(map[string]string)
+	errs := make([]error, 0, len(chain))
+	for _, e := range chain {
+		result, err := e.Extract(in)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			return nil, nil, err
+		}
+		if err != nil {
+			failures[e.Name()] = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		if result.Info == nil {
+			result.Info = &document.ResultInfo{}
+		}
+		result.Info.Extractor = e.Name()
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	comparison := Compare(results)
+	if len(failures) > 0 {
+		comparison.Failures = failures
+		comparison.Accepted = false
+	}
+	return results, comparison, nil
+}
+
+// Compare compares the records of extraction results field by field and vote by vote
+// Fields an extractor didn't extract aren't compared, missing votes are disagreements:
+func Compare(results []*document.ExtractionResult) *document.Comparison {
+	comparison := &document.Comparison{
+		Extractors:    make([]string, 0, len(results)),
+		Disagreements: make([]*document.Disagreement, 0),
+	}
+	for _, result := range results {
+		comparison.Extractors = append(comparison.Extractors, result.Info.Extractor)
+	}
+	fields := make([]string, 0)
+	fieldValues := make(map[string]map[string]string)
+	for _, result := range results {
+		for _, field := range result.Votes.Fields() {
+			if fieldValues[field.Name] == nil {
+				fields = append(fields, field.Name)
+				fieldValues[field.Name] = make(map[string]string)
+			}
+			if field.Value != "" {
+				fieldValues[field.Name][result.Info.Extractor] = field.Value
+			}
+		}
+	}
+	for _, field := range fields {
+		if values := fieldValues[field]; disagree(values, normalizeText) {
+			comparison.Disagreements = append(comparison.Disagreements, &document.Disagreement{
+				Field:  field,
+				Values: values,
+			})
+		}
+	}
+	comparison.Disagreements = append(comparison.Disagreements, compareTotals(results)...)
+	comparison.Disagreements = append(comparison.Disagreements, compareVotes(results)...)
+	comparison.Accepted = len(comparison.Disagreements) == 0
+	return comparison
+}
+
+// compareTotals compares the printed totals of the results that have them:
+func compareTotals(results []*document.ExtractionResult) []*document.Disagreement {
+	records := make([]*vote.Record, 0, len(results))
+	for _, result := range results {
+		records = append(records, result.Votes)
+	}
+	disagreements := make([]*document.Disagreement, 0)
+	for _, option := range vote.TotalOptions(records...) {
+		values := make(map[string]string)
+		for _, result := range results {
+			if len(result.Votes.Totals) == 0 {
+				continue
+			}
+			values[result.Info.Extractor] = result.Votes.TotalString(option)
+		}
+		if disagree(values, nil) {
+			disagreements = append(disagreements, &document.Disagreement{
+				Field:  "totals." + string(option),
+				Values: values,
+			})
+		}
+	}
+	return disagreements
+}
+
+// compareVotes compares the individual votes, legislators are matched using vote.NormalizeName:
+func compareVotes(results []*document.ExtractionResult) []*document.Disagreement {
+	names := make(map[string]string)
+	order := make([]string, 0)
+	for _, result := range results {
+		for _, v := range result.Votes.Votes {
+			normalized := vote.NormalizeName(v.Name)
+			if _, ok := names[normalized]; ok {
+				continue
+			}
+			names[normalized] = v.Name
+			order = append(order, normalized)
+		}
+	}
+	disagreements := make([]*document.Disagreement, 0)
+	for _, normalized := range order {
+		values := make(map[string]string)
+		for _, result := range results {
+			values[result.Info.Extractor] = ""
+			if v := result.Votes.VoteByName(names[normalized]); v != nil {
+				values[result.Info.Extractor] = string(v.Option)
+			}
+		}
+		if disagree(values, nil) {
+			disagreements = append(disagreements, &document.Disagreement{
+				Field:  FieldVote,
+				Name:   names[normalized],
+				Values: values,
+			})
+		}
+	}
+	return disagreements
+}
+
+// disagree reports if the values differ after normalizing them:
+func disagree(values map[string]string, normalize func(string) string) bool {
+	seen := make(map[string]bool)
+	for _, value := range values {
+		if normalize != nil {
+			value = normalize(value)
+		}
+		seen[value] = true
+	}
+	return len(seen) > 1
+}
+
+// normalizeText ignores case, accents and line wrapping differences:
+func normalizeText(s string) string {
+	return strings.Join(strings.Fields(accentReplacer.Replace(strings.ToLower(s))), " ")
+}
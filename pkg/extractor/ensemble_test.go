@@ -0,0 +1,149 @@
+package extractor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// baseRecord returns the record both extractors agree on unless a test changes it:
+func baseRecord() *vote.Record {
+	return &vote.Record{
+		Chamber: "senadores",
+		Date:    "2023-07-20",
+		Subject: "Proyecto de Ley de presupuesto",
+		Totals:  map[vote.Option]int{vote.Yes: 2, vote.No: 1},
+		Votes: []*vote.Vote{
+			{Name: "Juan Pérez", Option: vote.Yes},
+			{Name: "Ana Gómez", Option: vote.Yes},
+			{Name: "Luis Benítez", Option: vote.No},
+		},
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name string
+		// change modifies the record of the second extractor:
+		change func(r *vote.Record)
+		// disagreements are the expected "field name" pairs, in order:
+		disagreements []string
+	}{
+		{
+			name:   "agreeing results",
+			change: func(r *vote.Record) {},
+		},
+		{
+			// Names are matched regardless of their format:
+			name: "same votes with different name formats",
+			change: func(r *vote.Record) {
+				r.Votes[0].Name = "PEREZ, JUAN"
+			},
+		},
+		{
+			name: "one differing vote",
+			change: func(r *vote.Record) {
+				r.Votes[1].Option = vote.No
+			},
+			disagreements: []string{"vote Ana Gómez"},
+		},
+		{
+			name: "legislator missing from one result",
+			change: func(r *vote.Record) {
+				r.Votes = r.Votes[:2]
+			},
+			disagreements: []string{"vote Luis Benítez"},
+		},
+		{
+			name: "different totals",
+			change: func(r *vote.Record) {
+				r.Totals = map[vote.Option]int{vote.Yes: 3, vote.Abstention: 1}
+			},
+			disagreements: []string{"totals.abstencion", "totals.no", "totals.si"},
+		},
+		{
+			// Results without printed totals aren't compared:
+			name: "missing totals",
+			change: func(r *vote.Record) {
+				r.Totals = nil
+			},
+		},
+		{
+			name: "subject with different accents and case",
+			change: func(r *vote.Record) {
+				r.Subject = "PROYECTO DE LEY  de presupuésto"
+			},
+		},
+		{
+			name: "different subject",
+			change: func(r *vote.Record) {
+				r.Subject = "Proyecto de Ley de pensiones"
+			},
+			disagreements: []string{"subject"},
+		},
+		{
+			// Fields an extractor didn't extract aren't compared:
+			name: "missing date",
+			change: func(r *vote.Record) {
+				r.Date = ""
+			},
+		},
+		{
+			name: "different date",
+			change: func(r *vote.Record) {
+				r.Date = "2023-07-21"
+			},
+			disagreements: []string{"date"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			second := baseRecord()
+			tt.change(second)
+			comparison := Compare([]*document.ExtractionResult{
+				{Votes: baseRecord(), Info: &document.ResultInfo{Extractor: "text"}},
+				{Votes: second, Info: &document.ResultInfo{Extractor: "llm"}},
+			})
+			if fmt.Sprint(comparison.Extractors) != "[text llm]" {
+				t.Errorf("unexpected extractors %v", comparison.Extractors)
+			}
+			got := make([]string, 0, len(comparison.Disagreements))
+			for _, d := range comparison.Disagreements {
+				label := d.Field
+				if d.Name != "" {
+					label += " " + d.Name
+				}
+				got = append(got, label)
+			}
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.disagreements) {
+				t.Errorf("disagreements = %q, want %q", got, tt.disagreements)
+			}
+			if comparison.Accepted != (len(tt.disagreements) == 0) {
+				t.Errorf("accepted = %v with disagreements %q", comparison.Accepted, got)
+			}
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	second := baseRecord()
+	second.Votes = second.Votes[:2]
+	second.Totals[vote.No] = 0
+	comparison := Compare([]*document.ExtractionResult{
+		{Votes: baseRecord(), Info: &document.ResultInfo{Extractor: "text"}},
+		{Votes: second, Info: &document.ResultInfo{Extractor: "llm"}},
+	})
+	if len(comparison.Disagreements) != 2 {
+		t.Fatalf("expected 2 disagreements, got %d", len(comparison.Disagreements))
+	}
+	totals, missing := comparison.Disagreements[0], comparison.Disagreements[1]
+	if totals.Values["text"] != "1" || totals.Values["llm"] != "0" {
+		t.Errorf("unexpected totals values %v", totals.Values)
+	}
+	// The missing vote is recorded as an empty value:
+	if v, ok := missing.Values["llm"]; !ok || v != "" || missing.Values["text"] != string(vote.No) {
+		t.Errorf("unexpected vote values %v", missing.Values)
+	}
+}
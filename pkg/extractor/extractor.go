@@ -0,0 +1,117 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/openai"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/usage"
+)
+
+var (
+	// ErrNoExtractor is returned when a document type has no extractors:
+	ErrNoExtractor = errors.New("no extractor available for document type")
+
+	errUnknownExtractor = errors.New("unknown extractor")
+)
+
+// Page is a single document page:
+type Page struct {
+	// Index is the page index, starting at 0:
+	Index int
+	// ImagePath is the path to the rendered page:
+	ImagePath string
+	// Text is the PDF text layer of the page, empty for scanned pages:
+	Text string
+	// ProcessedImagePath is the preprocessed image of scanned pages, if any:
+	ProcessedImagePath string
+}
+
+// OCRImagePath returns the image used for OCR and image analysis, the preprocessed one when available:
+func (p *Page) OCRImagePath() string {
+	if p.ProcessedImagePath != "" {
+		return p.ProcessedImagePath
+	}
+	return p.ImagePath
+}
+
+// Input is the data passed to extractors:
+type Input struct {
+	Document *document.Document
+	Pages    []*Page
+	// Completer runs model calls, usage is attributed to the document:
+	Completer openai.Completer
+}
+
+// Extractor is implemented by every extraction strategy:
+type Extractor interface {
+	// Name is the name used in the document type config:
+	Name() string
+	// Extract returns the votes along with field confidences and raw evidence:
+	Extract(in *Input) (*document.ExtractionResult, error)
+}
+
+// Registry maps document types to an ordered chain of extractors:
+type Registry struct {
+	extractors map[string]Extractor
+	chains     map[types.DocumentType][]Extractor
+}
+
+// NewRegistry builds the extractor chains using the extractors set for each document type
+// Unknown extractor names are reported as an error:
+func NewRegistry(registry *types.Registry, extractors ...Extractor) (*Registry, error) {
+	r := &Registry{
+		extractors: make(map[string]Extractor),
+		chains:     make(map[types.DocumentType][]Extractor),
+	}
+	for _, e := range extractors {
+		r.extractors[e.Name()] = e
+	}
+	for _, definition := range registry.Definitions() {
+		for _, name := range definition.Extractors {
+			e, ok := r.extractors[name]
+			if !ok {
+				return nil, fmt.Errorf("document type %q: %w: %s", definition.Name, errUnknownExtractor, name)
+			}
+			r.chains[definition.Name] = append(r.chains[definition.Name], e)
+		}
+	}
+	return r, nil
+}
+
+// Chain returns the extractors for a document type, in order:
+func (r *Registry) Chain(t types.DocumentType) []Extractor {
+	return r.chains[t]
+}
+
+// Get returns an extractor by name:
+func (r *Registry) Get(name string) Extractor {
+	return r.extractors[name]
+}
+
+// Extract runs the chain of the document type, falling back to the next
+// extractor when one fails. Reaching the spend cap stops the chain:
+func (r *Registry) Extract(in *Input) (*document.ExtractionResult, error) {
+	chain := r.Chain(in.Document.Type)
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoExtractor, in.Document.Type)
+	}
+	errs := make([]error, 0, len(chain))
+	for _, e := range chain {
+		result, err := e.Extract(in)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		if result.Info != nil {
+			result.Info.Extractor = e.Name()
+		}
+		return result, nil
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,224 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// MinutesName is the extractor name used in the document type config:
+const MinutesName = "minutes_text"
+
+// duplicatesConfidence is the confidence of the votes when repeated names were merged,
+// it's below the review threshold so the document is flagged for a review:
+const duplicatesConfidence = 0.5
+
+var (
+	errNoTextLayer   = errors.New("document has no text layer")
+	errNoVotesFound  = errors.New("no nominal votes found")
+	errMultipleVotes = errors.New("more than one nominal vote found, the document must be reviewed")
+
+	pageNumberRe = regexp.MustCompile(`^\s*\d+\s*\n`)
+	chamberRe    = regexp.MustCompile(`(?i)c[aá]mara de (senadores|diputados)`)
+	sessionRe    = regexp.MustCompile(`SESI[OÓ]N (\p{Lu}+)`)
+	actaRe       = regexp.MustCompile(`ACTA N[º°o]\s*(\d+)`)
+	dateRe       = regexp.MustCompile(`(?i)a los ([\p{L} ]+?) d[ií]as? del mes de (\p{L}+) del año ((?:\p{L}+ ?){1,6})`)
+	timeRe       = regexp.MustCompile(`(?i)siendo las ([\p{L} ]+?) horas(?: con ([\p{L} ]+?) minutos)?`)
+	agendaItemRe = regexp.MustCompile(`(?:^|\s)\d+\.\s+(\p{Lu}[^.]+)\.`)
+	voteListRe   = regexp.MustCompile(`(?i)((?:votaron|abstenci[oó]n de)[^;:]{0,120}?(?:senadores|diputados|legisladores)[;:,]?)\s*(.+?)\.\s*TOTAL\s+(\d+)\s+\p{L}+`)
+	nameSplitRe  = regexp.MustCompile(`,\s*|\s+y\s+`)
+)
+
+// Minutes extracts nominal votes from the text layer of multi-page session minutes
+// -type "c" documents-. Pages are joined before parsing so lists of names that continue
+// on the next page are read as a single list:
+type Minutes struct{}
+
+// Name implements Extractor:
+func (e *Minutes) Name() string {
+	return MinutesName
+}
+
+// Extract extracts the vote record from the text of every page:
+func (e *Minutes) Extract(in *Input) (*document.ExtractionResult, error) {
+	texts := make([]string, 0, len(in.Pages))
+	for _, page := range in.Pages {
+		texts = append(texts, page.Text)
+	}
+	record, lists, duplicates, err := parseMinutes(texts)
+	if err != nil {
+		return nil, err
+	}
+	evidence := make([]*document.Evidence, 0, len(lists)+1)
+	for _, list := range lists {
+		evidence = append(evidence, &document.Evidence{
+			Field:  "votes",
+			Source: "text",
+			Raw:    list,
+		})
+	}
+	// Values are read from the text layer, fields are either found or not:
+	confidence := map[string]float64{"votes": 1}
+	if len(duplicates) > 0 {
+		evidence = append(evidence, &document.Evidence{
+			Field:  "duplicates",
+			Source: "text",
+			Raw:    strings.Join(duplicates, ", "),
+		})
+		confidence["votes"] = duplicatesConfidence
+	}
+	for field, value := range map[string]string{
+		"chamber": record.Chamber,
+		"date":    record.Date,
+		"time":    record.Time,
+		"subject": record.Subject,
+	} {
+		if value != "" {
+			confidence[field] = 1
+		}
+	}
+	return &document.ExtractionResult{
+		Votes:      record,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Info: &document.ResultInfo{
+			Method:    "text",
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// joinPages removes the page numbers at the top of each page and
+// joins the pages into a single line of text:
+func joinPages(texts []string) string {
+	pages := make([]string, 0, len(texts))
+	for _, text := range texts {
+		pages = append(pages, pageNumberRe.ReplaceAllString(text, ""))
+	}
+	return strings.Join(strings.Fields(strings.Join(pages, "\n")), " ")
+}
+
+// parseMinutes parses the header block and the vote lists of a single nominal vote, the text of
+// every list and the names repeated within a list -usually at a page boundary- are also returned
+// Each total is the "TOTAL n" that closes its list, on the page where the list ends. A repeated
+// option means the minutes have more than one nominal vote, those aren't merged into a single record:
+func parseMinutes(texts []string) (*vote.Record, []string, []string, error) {
+	text := joinPages(texts)
+	if text == "" {
+		return nil, nil, nil, errNoTextLayer
+	}
+	record := &vote.Record{
+		Totals: make(map[vote.Option]int),
+		Votes:  make([]*vote.Vote, 0),
+	}
+	parseMinutesHeader(text, record)
+
+	matches := voteListRe.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return nil, nil, nil, errNoVotesFound
+	}
+
+	// The subject is the last agenda item before the first vote:
+	for _, item := range agendaItemRe.FindAllStringSubmatch(text[:matches[0][0]], -1) {
+		record.Subject = strings.TrimSpace(item[1])
+	}
+
+	lists := make([]string, 0, len(matches))
+	duplicates := make([]string, 0)
+	previousEnd, motions := 0, 0
+	for _, match := range matches {
+		lists = append(lists, text[match[0]:match[1]])
+		context := strings.ToLower(text[previousEnd:match[0]] + text[match[2]:match[3]])
+		previousEnd = match[1]
+		var option vote.Option
+		switch {
+		case strings.Contains(context, "abstenci"):
+			option = vote.Abstention
+		case strings.Contains(context, "afirmativa"):
+			option = vote.Yes
+		case strings.Contains(context, "negativa"):
+			option = vote.No
+		default:
+			motions++
+			option = vote.MotionOption(motions)
+		}
+		if _, ok := record.Totals[option]; ok {
+			return nil, nil, nil, fmt.Errorf("%w: a second %s list", errMultipleVotes, option)
+		}
+		total, _ := strconv.Atoi(text[match[6]:match[7]])
+		record.Totals[option] = total
+		// Names repeated in the same list are merged, names in more than one list are
+		// kept so the validation reports them as duplicated votes:
+		seen := make(map[string]bool)
+		for _, name := range nameSplitRe.Split(text[match[4]:match[5]], -1) {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			normalized := vote.NormalizeName(name)
+			if seen[normalized] {
+				duplicates = append(duplicates, name)
+				continue
+			}
+			seen[normalized] = true
+			record.Votes = append(record.Votes, &vote.Vote{
+				Name:   name,
+				Option: option,
+			})
+		}
+	}
+	return record, lists, duplicates, nil
+}
+
+// parseMinutesHeader reads the chamber, date and time from the opening paragraph:
+func parseMinutesHeader(text string, record *vote.Record) {
+	if match := chamberRe.FindStringSubmatch(text); match != nil {
+		record.Chamber = strings.ToLower(match[1])
+	}
+	if match := dateRe.FindStringSubmatch(text); match != nil {
+		day, dayOK := parseSpanishNumber(match[1])
+		month, monthOK := spanishMonths[strings.ToLower(match[2])]
+		year, yearOK := parseLeadingNumber(match[3])
+		if dayOK && monthOK && yearOK {
+			record.Date = fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+		}
+	}
+	if match := timeRe.FindStringSubmatch(text); match != nil {
+		hours, hoursOK := parseSpanishNumber(match[1])
+		minutes, minutesOK := parseSpanishNumber(match[2])
+		if !minutesOK {
+			minutes = 0
+		}
+		if hoursOK {
+			record.Time = fmt.Sprintf("%02d:%02d:00", hours, minutes)
+		}
+	}
+	// The session and minutes number are used as subject unless an agenda item is found:
+	session := sessionRe.FindStringSubmatch(text)
+	acta := actaRe.FindStringSubmatch(text)
+	if session != nil && acta != nil {
+		record.Subject = fmt.Sprintf("Sesión %s - Acta Nº %s", strings.ToLower(session[1]), acta[1])
+	}
+}
+
+// parseLeadingNumber parses the longest sequence of number words at the start of text:
+func parseLeadingNumber(text string) (int, bool) {
+	words := strings.Fields(text)
+	for i := len(words); i > 0; i-- {
+		if n, ok := parseSpanishNumber(strings.Join(words[:i], " ")); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// NewMinutes initializes the minutes extractor:
+func NewMinutes() *Minutes {
+	return &Minutes{}
+}
@@ -0,0 +1,188 @@
+package extractor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// sampleCTexts returns the text layer of sample/sample_c.pdf, one file per page:
+func sampleCTexts(t *testing.T) []string {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("testdata", "sample_c_*.txt"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("missing sample_c pages: %v", err)
+	}
+	texts := make([]string, 0, len(paths))
+	for _, path := range paths {
+		text, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, string(text))
+	}
+	return texts
+}
+
+func TestParseMinutesSampleC(t *testing.T) {
+	record, lists, duplicates, err := parseMinutes(sampleCTexts(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if record.Chamber != "senadores" || record.Date != "2023-06-30" || record.Time != "15:22:00" {
+		t.Errorf("unexpected header: chamber %q, date %q, time %q", record.Chamber, record.Date, record.Time)
+	}
+	if want := "Elección del Vicepresidente Primero de la Cámara de Senadores"; record.Subject != want {
+		t.Errorf("subject = %q, want %q", record.Subject, want)
+	}
+	if len(lists) != 3 {
+		t.Errorf("expected 3 lists, got %d", len(lists))
+	}
+	if len(duplicates) != 0 {
+		t.Errorf("unexpected duplicates: %v", duplicates)
+	}
+	if len(record.Votes) != 43 {
+		t.Errorf("expected 43 votes, got %d", len(record.Votes))
+	}
+	wantTotals := map[vote.Option]int{vote.MotionOption(1): 14, vote.MotionOption(2): 27, vote.Abstention: 2}
+	if len(record.Totals) != len(wantTotals) {
+		t.Errorf("totals = %v, want %v", record.Totals, wantTotals)
+	}
+	for option, total := range wantTotals {
+		if record.Totals[option] != total {
+			t.Errorf("totals[%s] = %d, want %d", option, record.Totals[option], total)
+		}
+	}
+	for option, count := range record.Count() {
+		if count != wantTotals[option] {
+			t.Errorf("counted %d %s votes, the printed total is %d", count, option, wantTotals[option])
+		}
+	}
+	tests := []struct {
+		name   string
+		option vote.Option
+	}{
+		{"Norma Aquino", vote.MotionOption(1)},
+		{"Celeste Amarilla Vda. De Boccia", vote.MotionOption(1)},
+		{"Ever Villalba", vote.MotionOption(1)},
+		{"Juan Afara", vote.MotionOption(2)},
+		// The name continues on the next page:
+		{"Arnaldo Samaniego", vote.MotionOption(2)},
+		{"Javier Zacarías", vote.MotionOption(2)},
+		{"Blanca Ovelar", vote.Abstention},
+		{"Sergio Rojas", vote.Abstention},
+	}
+	for _, tt := range tests {
+		v := record.VoteByName(tt.name)
+		if v == nil {
+			t.Errorf("missing vote of %s", tt.name)
+			continue
+		}
+		if v.Option != tt.option {
+			t.Errorf("%s voted %s, want %s", tt.name, v.Option, tt.option)
+		}
+	}
+}
+
+func TestParseMinutes(t *testing.T) {
+	const header = "SESIÓN ORDINARIA ACTA Nº 7 En la ciudad de Asunción, a los cinco días del mes de julio del año dos mil veintitrés siendo las diez horas se reúne la Cámara de Senadores. "
+	tests := []struct {
+		name       string
+		texts      []string
+		err        error
+		votes      int
+		totals     map[vote.Option]int
+		duplicates []string
+	}{
+		{
+			name:  "no text layer",
+			texts: []string{"", "  "},
+			err:   errNoTextLayer,
+		},
+		{
+			name:  "no votes",
+			texts: []string{header + "Se levanta la sesión."},
+			err:   errNoVotesFound,
+		},
+		{
+			name: "single vote",
+			texts: []string{header + "Por la afirmativa votaron los señores senadores; Juan Pérez, Ana Gómez y Luis Benítez. TOTAL 3 VOTOS. " +
+				"Por la negativa votaron los señores senadores; Rosa Acosta. TOTAL 1 VOTO."},
+			votes:  4,
+			totals: map[vote.Option]int{vote.Yes: 3, vote.No: 1},
+		},
+		{
+			name: "list continued on the next page",
+			texts: []string{
+				"1\n" + header + "Por la afirmativa votaron los señores senadores; Juan Pérez, Ana\n",
+				"2\nGómez y Luis Benítez. TOTAL 3 VOTOS.\n",
+			},
+			votes:  3,
+			totals: map[vote.Option]int{vote.Yes: 3},
+		},
+		{
+			name: "name repeated in a list",
+			texts: []string{
+				"1\n" + header + "Por la afirmativa votaron los señores senadores; Juan Pérez, Ana Gómez,\n",
+				"2\nAna Gómez y Luis Benítez. TOTAL 3 VOTOS.\n",
+			},
+			votes:      3,
+			totals:     map[vote.Option]int{vote.Yes: 3},
+			duplicates: []string{"Ana Gómez"},
+		},
+		{
+			// Names in more than one list are kept for the validation to report them:
+			name: "name in two lists",
+			texts: []string{header + "Por la afirmativa votaron los señores senadores; Juan Pérez y Ana Gómez. TOTAL 2 VOTOS. " +
+				"Por la negativa votaron los señores senadores; Ana Gómez. TOTAL 1 VOTO."},
+			votes:  3,
+			totals: map[vote.Option]int{vote.Yes: 2, vote.No: 1},
+		},
+		{
+			name: "two nominal votes",
+			texts: []string{header + "Por la afirmativa votaron los señores senadores; Juan Pérez. TOTAL 1 VOTO. " +
+				"Por la negativa votaron los señores senadores; Ana Gómez. TOTAL 1 VOTO. " +
+				"Luego, por la afirmativa votaron los señores senadores; Ana Gómez y Juan Pérez. TOTAL 2 VOTOS."},
+			err: errMultipleVotes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, _, duplicates, err := parseMinutes(tt.texts)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if record.Chamber != "senadores" || record.Date != "2023-07-05" || record.Time != "10:00:00" {
+				t.Errorf("unexpected header: chamber %q, date %q, time %q", record.Chamber, record.Date, record.Time)
+			}
+			if len(record.Votes) != tt.votes {
+				t.Errorf("expected %d votes, got %d", tt.votes, len(record.Votes))
+			}
+			if len(record.Totals) != len(tt.totals) {
+				t.Errorf("totals = %v, want %v", record.Totals, tt.totals)
+			}
+			for option, total := range tt.totals {
+				if record.Totals[option] != total {
+					t.Errorf("totals[%s] = %d, want %d", option, record.Totals[option], total)
+				}
+			}
+			if len(duplicates) != len(tt.duplicates) {
+				t.Fatalf("duplicates = %v, want %v", duplicates, tt.duplicates)
+			}
+			for i := range duplicates {
+				if duplicates[i] != tt.duplicates[i] {
+					t.Errorf("duplicates = %v, want %v", duplicates, tt.duplicates)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"os"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/ocr"
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/layout"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// TemplateName is the name of the positional extractor:
+const TemplateName = "template"
+
+var (
+	errNoTemplate = errors.New("no template for document type")
+)
+
+// Positional reads the regions defined in the layout template of the document type
+// Pages with a text layer use it, scanned pages are recognized with OCR:
+type Positional struct {
+	templates map[string]*layout.Template
+	ocr       *ocr.Engine
+}
+
+// Name implements Extractor:
+func (e *Positional) Name() string {
+	return TemplateName
+}
+
+// Extract reads every region of the template:
+func (e *Positional) Extract(in *Input) (*document.ExtractionResult, error) {
+	t, ok := e.templates[string(in.Document.Type)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errNoTemplate, in.Document.Type)
+	}
+	pages, method, err := e.pageBoxes(in)
+	if err != nil {
+		return nil, err
+	}
+	record := &vote.Record{
+		Votes: make([]*vote.Vote, 0),
+	}
+	evidence := make([]*document.Evidence, 0)
+	confidence := make(map[string]float64)
+	for _, region := range t.Regions {
+		for i, boxes := range pages {
+			if !region.AllPages && i != region.Page {
+				continue
+			}
+			for _, value := range region.Values(boxes) {
+				evidence = append(evidence, &document.Evidence{
+					Field:  region.Field,
+					Page:   i,
+					Source: region.Name,
+					Raw:    value,
+				})
+				if err := setField(record, region, value); err != nil {
+					return nil, fmt.Errorf("region %q: %w", region.Name, err)
+				}
+				confidence[region.Field] = 1
+			}
+		}
+	}
+	if len(record.Votes) == 0 {
+		return nil, errNoVotesFound
+	}
+	return &document.ExtractionResult{
+		Votes:      record,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Info: &document.ResultInfo{
+			Method:    method,
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// pageBoxes returns the text boxes of every page, from the text layer when available:
+func (e *Positional) pageBoxes(in *Input) ([][]layout.Box, string, error) {
+	hasText := len(in.Pages) > 0
+	for _, page := range in.Pages {
+		if page.Text == "" {
+			hasText = false
+		}
+	}
+	pages := make([][]layout.Box, 0, len(in.Pages))
+	if hasText {
+		textBoxes, err := pdf2png.GetPageTextBoxes(in.Document.PDFPath)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, boxes := range textBoxes {
+			pages = append(pages, layout.BoxesFromText(boxes))
+		}
+		return pages, "text", nil
+	}
+	for _, page := range in.Pages {
+		width, height, err := imageSize(page.OCRImagePath())
+		if err != nil {
+			return nil, "", err
+		}
+		words, err := e.ocr.Recognize(page.OCRImagePath())
+		if err != nil {
+			return nil, "", err
+		}
+		pages = append(pages, layout.BoxesFromOCR(words, width, height))
+	}
+	return pages, "ocr", nil
+}
+
+// setField stores a region value in the record:
+func setField(record *vote.Record, region *layout.Region, value string) error {
+	switch region.Field {
+	case layout.FieldChamber:
+		record.Chamber = value
+	case layout.FieldDate:
+		if region.Format != "" {
+			t, err := time.Parse(region.Format, value)
+			if err != nil {
+				return err
+			}
+			value = t.Format("2006-01-02")
+		}
+		record.Date = value
+	case layout.FieldTime:
+		if region.Format != "" {
+			t, err := time.Parse(region.Format, value)
+			if err != nil {
+				return err
+			}
+			value = t.Format("15:04:05")
+		}
+		record.Time = value
+	case layout.FieldSubject:
+		record.Subject = value
+	case layout.FieldVotes:
+		record.Votes = append(record.Votes, &vote.Vote{
+			Name:       value,
+			Option:     region.Option,
+			Confidence: 1,
+		})
+	}
+	return nil
+}
+
+// imageSize returns the size of an image in pixels:
+func imageSize(imagePath string) (int, int, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	c, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	return c.Width, c.Height, nil
+}
+
+// NewPositional initializes the positional extractor with the loaded templates:
+func NewPositional(cfg *config.Config, templates map[string]*layout.Template) *Positional {
+	return &Positional{
+		templates: templates,
+		ocr:       ocr.New(cfg.ExtractionConfig.TesseractPath, cfg.ExtractionConfig.Language),
+	}
+}
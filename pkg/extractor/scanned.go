@@ -0,0 +1,444 @@
+package extractor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/ocr"
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/openai"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/marks"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/prompts"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// ScannedTableName is the extractor name used in the document type config:
+const ScannedTableName = "scanned_table"
+
+// Extraction backends:
+const (
+	BackendOpenAI    = "openai"
+	BackendTesseract = "tesseract"
+)
+
+const (
+	// noMarkedOption is returned by the model for rows without a mark:
+	noMarkedOption = "ninguno"
+	// minMarkScore is the filled score of a cell considered marked:
+	minMarkScore = 0.3
+)
+
+var (
+	errUnknownBackend   = errors.New("unknown extraction backend")
+	errTableNotFound    = errors.New("vote table header not found")
+	errNoRowsExtracted  = errors.New("no rows extracted")
+	scannedTableColumns = []struct {
+		keyword string
+		option  vote.Option
+	}{
+		{"aprobaci", vote.Yes},
+		{"rechazo", vote.No},
+		{"abst", vote.Abstention},
+		{"ausente", vote.Absent},
+	}
+)
+
+// ScannedTable extracts votes from scanned sheets with a legislator table
+// and one column per vote option -type "b" documents-:
+type ScannedTable struct {
+	cfg     *config.Config
+	prompts *prompts.Prompts
+	ocr     *ocr.Engine
+}
+
+// scannedTableOutput is the structure requested to the model:
+type scannedTableOutput struct {
+	Subject string             `json:"subject" jsonschema:"description=Subject written at the top of the sheet"`
+	Rows    []scannedTableRow  `json:"rows"`
+	Totals  scannedTableTotals `json:"totals"`
+}
+
+type scannedTableRow struct {
+	Name       string  `json:"name"`
+	Option     string  `json:"option" jsonschema:"enum=si|no|abstencion|ausente|ninguno"`
+	Confidence float64 `json:"confidence" jsonschema:"minimum=0,maximum=1"`
+}
+
+type scannedTableTotals struct {
+	Yes        int `json:"si"`
+	No         int `json:"no"`
+	Abstention int `json:"abstencion"`
+	Absent     int `json:"ausente"`
+}
+
+// Name implements Extractor:
+func (e *ScannedTable) Name() string {
+	return ScannedTableName
+}
+
+// Extract extracts the vote record of every page using the configured backend:
+func (e *ScannedTable) Extract(in *Input) (*document.ExtractionResult, error) {
+	switch e.cfg.ExtractionConfig.Backend {
+	case "", BackendOpenAI:
+		return e.extractWithModel(in)
+	case BackendTesseract:
+		return e.extractWithOCR(in)
+	}
+	return nil, fmt.Errorf("%w: %s", errUnknownBackend, e.cfg.ExtractionConfig.Backend)
+}
+
+// extractWithModel asks a vision model to read the table:
+func (e *ScannedTable) extractWithModel(in *Input) (*document.ExtractionResult, error) {
+	prompt, err := e.prompts.Render(prompts.ScannedTable, nil)
+	if err != nil {
+		return nil, err
+	}
+	record := &vote.Record{
+		Votes: make([]*vote.Vote, 0),
+	}
+	evidence := make([]*document.Evidence, 0)
+	var model string
+	for _, page := range in.Pages {
+		image, err := document.ImageFileAsBase64(page.ImagePath)
+		if err != nil {
+			return nil, err
+		}
+		req := openai.CompletionRequest{
+			MaxTokens: 4000,
+			Messages: []openai.Message{
+				{Role: "user", Content: []openai.ContentItem{
+					{Type: "text", Text: prompt.Text},
+					{Type: "image_url", ImageURL: &openai.ImageURL{URL: "data:image/png;base64," + image}},
+				}},
+			},
+		}
+		var output scannedTableOutput
+		res, err := openai.StructuredCompletion(in.Completer, &req, &output, openai.StructuredOptions{
+			Name:           prompts.ScannedTable,
+			ResponseFormat: e.cfg.OpenAIConfig.ResponseFormat,
+			MaxAttempts:    e.cfg.OpenAIConfig.MaxAttempts,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Index, err)
+		}
+		model = req.Model
+		evidence = append(evidence, &document.Evidence{
+			Field:  "votes",
+			Page:   page.Index,
+			Source: "llm",
+			Raw:    res.Choices[0].Message.Content,
+		})
+		if record.Subject == "" {
+			record.Subject = strings.TrimSpace(output.Subject)
+		}
+		for _, row := range output.Rows {
+			if row.Option == noMarkedOption || strings.TrimSpace(row.Name) == "" {
+				continue
+			}
+			record.Votes = append(record.Votes, &vote.Vote{
+				Name:       strings.TrimSpace(row.Name),
+				Option:     vote.Option(row.Option),
+				Confidence: row.Confidence,
+			})
+		}
+		totals := output.Totals
+		if totals.Yes+totals.No+totals.Abstention+totals.Absent > 0 {
+			record.Totals = map[vote.Option]int{
+				vote.Yes:        totals.Yes,
+				vote.No:         totals.No,
+				vote.Abstention: totals.Abstention,
+				vote.Absent:     totals.Absent,
+			}
+		}
+	}
+	if len(record.Votes) == 0 {
+		return nil, errNoRowsExtracted
+	}
+	return &document.ExtractionResult{
+		Votes:      record,
+		Confidence: rowConfidence(record),
+		Evidence:   evidence,
+		Info: &document.ResultInfo{
+			Method:        "llm",
+			Model:         model,
+			Prompt:        prompt.Name,
+			PromptVersion: prompt.Version,
+			CreatedAt:     time.Now(),
+		},
+	}, nil
+}
+
+// rowConfidence returns the average and minimum row confidence as field confidences:
+func rowConfidence(record *vote.Record) map[string]float64 {
+	confidence := map[string]float64{
+		"votes":     0,
+		"votes_min": 1,
+	}
+	for _, v := range record.Votes {
+		confidence["votes"] += v.Confidence
+		if v.Confidence < confidence["votes_min"] {
+			confidence["votes_min"] = v.Confidence
+		}
+	}
+	if len(record.Votes) > 0 {
+		confidence["votes"] /= float64(len(record.Votes))
+	}
+	return confidence
+}
+
+// ocrLine is a group of words on the same line:
+type ocrLine struct {
+	words  []ocr.Word
+	top    int
+	bottom int
+}
+
+// extractWithOCR reads the table using tesseract: the vote columns are located using
+// their headers and words on the left are the legislator names. Marks are found by
+// scoring the cells of the ruled grid, when the grid can't be located any word recognized
+// inside a vote column -usually an X- is taken as the mark for that row:
+func (e *ScannedTable) extractWithOCR(in *Input) (*document.ExtractionResult, error) {
+	record := &vote.Record{
+		Votes: make([]*vote.Vote, 0),
+	}
+	evidence := make([]*document.Evidence, 0)
+	for _, page := range in.Pages {
+		words, err := e.ocr.Recognize(page.OCRImagePath())
+		if err != nil {
+			return nil, err
+		}
+		// Without a grid the marks recognized by OCR are used:
+		grid, _ := marks.Detect(page.OCRImagePath())
+		lines, err := readScannedTable(words, grid, record)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", page.Index, err)
+		}
+		for _, line := range lines {
+			evidence = append(evidence, &document.Evidence{
+				Field:  "votes",
+				Page:   page.Index,
+				Source: "ocr",
+				Raw:    line,
+			})
+		}
+	}
+	if len(record.Votes) == 0 {
+		return nil, errNoRowsExtracted
+	}
+	return &document.ExtractionResult{
+		Votes:      record,
+		Confidence: rowConfidence(record),
+		Evidence:   evidence,
+		Info: &document.ResultInfo{
+			Method:    "ocr",
+			Model:     BackendTesseract,
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// readScannedTable appends the rows found in the words of a page to the record,
+// the text of the lines used for each row is returned as evidence. The grid is optional:
+func readScannedTable(words []ocr.Word, grid *marks.Grid, record *vote.Record) ([]string, error) {
+	// Locate the vote column headers, the keywords may appear in the subject above the table
+	// so only the first line containing every header is used:
+	lines := groupLines(words)
+	var columnX map[vote.Option]int
+	header := -1
+	for i, line := range lines {
+		if columnX = headerColumns(line); columnX != nil {
+			header = i
+			break
+		}
+	}
+	if header == -1 {
+		return nil, errTableNotFound
+	}
+	firstColumnX, columnSpacing := columnX[vote.Yes], columnX[vote.No]-columnX[vote.Yes]
+	nameLimit := firstColumnX - columnSpacing/2
+
+	evidence := make([]string, 0)
+	for _, line := range lines[header+1:] {
+		nameWords := make([]string, 0)
+		var nameConfidence float64
+		for _, w := range line.words {
+			if w.CenterX() < nameLimit {
+				nameWords = append(nameWords, w.Text)
+				nameConfidence += w.Confidence
+			}
+		}
+		if len(nameWords) == 0 {
+			continue
+		}
+		name := strings.Join(nameWords, " ")
+		nameConfidence /= float64(len(nameWords))
+
+		// The totals row closes the table:
+		if strings.HasPrefix(strings.ToUpper(name), "TOTAL") {
+			record.Totals = readTotals(line, columnX, columnSpacing)
+			evidence = append(evidence, line.String())
+			break
+		}
+
+		option, markConfidence := findGridMark(grid, line, columnX)
+		if option == "" {
+			option, markConfidence = findMark(line, columnX, columnSpacing, nameLimit)
+		}
+		if option == "" {
+			continue
+		}
+		record.Votes = append(record.Votes, &vote.Vote{
+			Name:       name,
+			Option:     option,
+			Confidence: nameConfidence * markConfidence,
+		})
+		evidence = append(evidence, line.String())
+	}
+	return evidence, nil
+}
+
+// headerColumns returns the center of every vote column header found in a line,
+// nil is returned unless the line has all of them:
+func headerColumns(line *ocrLine) map[vote.Option]int {
+	columnX := make(map[vote.Option]int)
+	for _, w := range line.words {
+		text := strings.ToLower(w.Text)
+		for _, column := range scannedTableColumns {
+			if _, found := columnX[column.option]; !found && strings.Contains(text, column.keyword) {
+				columnX[column.option] = w.CenterX()
+			}
+		}
+	}
+	if len(columnX) < len(scannedTableColumns) {
+		return nil
+	}
+	return columnX
+}
+
+// String returns the words of the line:
+func (l *ocrLine) String() string {
+	words := make([]string, 0, len(l.words))
+	for _, w := range l.words {
+		words = append(words, w.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+// groupLines groups words in lines using their vertical overlap:
+func groupLines(words []ocr.Word) []*ocrLine {
+	sorted := append([]ocr.Word{}, words...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CenterY() < sorted[j].CenterY()
+	})
+	lines := make([]*ocrLine, 0)
+	for _, w := range sorted {
+		if len(lines) > 0 {
+			last := lines[len(lines)-1]
+			if w.CenterY() >= last.top && w.CenterY() <= last.bottom {
+				last.words = append(last.words, w)
+				continue
+			}
+		}
+		lines = append(lines, &ocrLine{
+			words:  []ocr.Word{w},
+			top:    w.Top,
+			bottom: w.Top + w.Height,
+		})
+	}
+	for _, line := range lines {
+		sort.Slice(line.words, func(i, j int) bool {
+			return line.words[i].Left < line.words[j].Left
+		})
+	}
+	return lines
+}
+
+// nearestColumn returns the option of the column closest to x, if it's within half the column spacing:
+func nearestColumn(x int, columnX map[vote.Option]int, columnSpacing int) vote.Option {
+	var nearest vote.Option
+	best := columnSpacing / 2
+	for option, cx := range columnX {
+		distance := x - cx
+		if distance < 0 {
+			distance = -distance
+		}
+		if distance <= best {
+			nearest, best = option, distance
+		}
+	}
+	return nearest
+}
+
+// findMark returns the column with a recognized mark in a line:
+func findMark(line *ocrLine, columnX map[vote.Option]int, columnSpacing, nameLimit int) (vote.Option, float64) {
+	var option vote.Option
+	var confidence float64
+	for _, w := range line.words {
+		if w.CenterX() < nameLimit {
+			continue
+		}
+		if column := nearestColumn(w.CenterX(), columnX, columnSpacing); column != "" && w.Confidence >= confidence {
+			option, confidence = column, w.Confidence
+		}
+	}
+	return option, confidence
+}
+
+// findGridMark returns the vote column with the highest filled score in the grid row of a line
+// The confidence is the difference with the next best column, so rows with two marks score low:
+func findGridMark(grid *marks.Grid, line *ocrLine, columnX map[vote.Option]int) (vote.Option, float64) {
+	if grid == nil {
+		return "", 0
+	}
+	row := grid.Row((line.top + line.bottom) / 2)
+	if row == -1 {
+		return "", 0
+	}
+	var option vote.Option
+	best, second := 0.0, 0.0
+	for o, x := range columnX {
+		column := grid.Column(x)
+		if column == -1 {
+			continue
+		}
+		switch score := grid.Scores[row][column]; {
+		case score > best:
+			option, best, second = o, score, best
+		case score > second:
+			second = score
+		}
+	}
+	if best < minMarkScore {
+		return "", 0
+	}
+	return option, best - second
+}
+
+// readTotals reads the numbers of the totals row:
+func readTotals(line *ocrLine, columnX map[vote.Option]int, columnSpacing int) map[vote.Option]int {
+	totals := make(map[vote.Option]int)
+	for _, w := range line.words {
+		n, err := strconv.Atoi(strings.Trim(w.Text, ".:"))
+		if err != nil {
+			continue
+		}
+		if column := nearestColumn(w.CenterX(), columnX, columnSpacing); column != "" {
+			totals[column] = n
+		}
+	}
+	return totals
+}
+
+// NewScannedTable initializes the scanned table extractor:
+func NewScannedTable(cfg *config.Config, p *prompts.Prompts) *ScannedTable {
+	return &ScannedTable{
+		cfg:     cfg,
+		prompts: p,
+		ocr:     ocr.New(cfg.ExtractionConfig.TesseractPath, cfg.ExtractionConfig.Language),
+	}
+}
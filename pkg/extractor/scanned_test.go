@@ -0,0 +1,57 @@
+package extractor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/ocr"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// ocrLineWords returns the words of a line, texts are placed at the given x positions:
+func ocrLineWords(y int, texts map[int]string) []ocr.Word {
+	words := make([]ocr.Word, 0, len(texts))
+	for x, text := range texts {
+		words = append(words, ocr.Word{Text: text, Left: x, Top: y, Width: 10 * len(text), Height: 20, Confidence: 1})
+	}
+	return words
+}
+
+func TestReadScannedTable(t *testing.T) {
+	words := make([]ocr.Word, 0)
+	// The subject mentions the approval above the table, at the position of another column:
+	words = append(words, ocrLineWords(50, map[int]string{100: "Solicitud", 200: "de", 600: "aprobación", 800: "del", 900: "proyecto"})...)
+	words = append(words, ocrLineWords(100, map[int]string{100: "Senador", 400: "Aprobación", 550: "Rechazo", 700: "Abstención", 850: "Ausente"})...)
+	words = append(words, ocrLineWords(150, map[int]string{100: "Juan", 160: "Pérez", 440: "X"})...)
+	words = append(words, ocrLineWords(200, map[int]string{100: "Ana", 150: "Gómez", 590: "X"})...)
+	words = append(words, ocrLineWords(250, map[int]string{100: "Luis", 160: "Benítez", 880: "X"})...)
+	words = append(words, ocrLineWords(300, map[int]string{100: "TOTAL", 445: "1", 595: "1", 745: "0", 885: "1"})...)
+
+	record := &vote.Record{}
+	evidence, err := readScannedTable(words, nil, record)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]vote.Option{"Juan Pérez": vote.Yes, "Ana Gómez": vote.No, "Luis Benítez": vote.Absent}
+	if len(record.Votes) != len(want) {
+		t.Fatalf("expected %d votes, got %d: %v", len(want), len(record.Votes), evidence)
+	}
+	for _, v := range record.Votes {
+		if want[v.Name] != v.Option {
+			t.Errorf("%s voted %s, want %s", v.Name, v.Option, want[v.Name])
+		}
+	}
+	if record.Totals[vote.Yes] != 1 || record.Totals[vote.No] != 1 || record.Totals[vote.Absent] != 1 {
+		t.Errorf("unexpected totals %v", record.Totals)
+	}
+	if len(evidence) != 4 || !strings.HasPrefix(evidence[3], "TOTAL") {
+		t.Errorf("unexpected evidence %v", evidence)
+	}
+
+	// Without a line containing every header there's no table:
+	_, err = readScannedTable(words[:5], nil, &vote.Record{})
+	if !errors.Is(err, errTableNotFound) {
+		t.Errorf("error = %v, want %v", err, errTableNotFound)
+	}
+}
@@ -0,0 +1,56 @@
+package extractor
+
+import (
+	"strings"
+)
+
+// spanishNumbers maps number words to their values, accents are removed before the lookup:
+var spanishNumbers = map[string]int{
+	"un": 1, "uno": 1, "una": 1, "primero": 1, "primer": 1, "dos": 2, "tres": 3, "cuatro": 4,
+	"cinco": 5, "seis": 6, "siete": 7, "ocho": 8, "nueve": 9, "diez": 10, "once": 11, "doce": 12,
+	"trece": 13, "catorce": 14, "quince": 15, "dieciseis": 16, "diecisiete": 17, "dieciocho": 18,
+	"diecinueve": 19, "veinte": 20, "veintiun": 21, "veintiuno": 21, "veintidos": 22,
+	"veintitres": 23, "veinticuatro": 24, "veinticinco": 25, "veintiseis": 26, "veintisiete": 27,
+	"veintiocho": 28, "veintinueve": 29, "treinta": 30, "cuarenta": 40, "cincuenta": 50,
+	"sesenta": 60, "setenta": 70, "ochenta": 80, "noventa": 90, "cien": 100, "ciento": 100,
+	"doscientos": 200, "trescientos": 300, "cuatrocientos": 400, "quinientos": 500,
+	"seiscientos": 600, "setecientos": 700, "ochocientos": 800, "novecientos": 900,
+}
+
+// spanishMonths maps month names to their number:
+var spanishMonths = map[string]int{
+	"enero": 1, "febrero": 2, "marzo": 3, "abril": 4, "mayo": 5, "junio": 6, "julio": 7,
+	"agosto": 8, "septiembre": 9, "setiembre": 9, "octubre": 10, "noviembre": 11, "diciembre": 12,
+}
+
+// accentReplacer removes the accents used in number words:
+var accentReplacer = strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u")
+
+// parseSpanishNumber converts a spelled out number -e.g. "dos mil veintitrés"- into its value
+// The second return value is false when a word isn't a number word:
+func parseSpanishNumber(text string) (int, bool) {
+	words := strings.Fields(accentReplacer.Replace(strings.ToLower(text)))
+	if len(words) == 0 {
+		return 0, false
+	}
+	total, current := 0, 0
+	for _, word := range words {
+		switch word {
+		case "y":
+			continue
+		case "mil":
+			if current == 0 {
+				current = 1
+			}
+			total += current * 1000
+			current = 0
+			continue
+		}
+		value, ok := spanishNumbers[word]
+		if !ok {
+			return 0, false
+		}
+		current += value
+	}
+	return total + current, true
+}
@@ -0,0 +1,236 @@
+package extractor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/table"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// VoteTableName is the name of the digital vote sheet extractor:
+const VoteTableName = "vote_table"
+
+var (
+	// sectionRe matches the option headers, e.g. "Si( 23 )" or "No votan( 5 )":
+	sectionRe = regexp.MustCompile(`^(S[ií]|No|Abstenci[oó]n(?:es)?|No votan|Ausentes?)\s*\(\s*(\d+)\s*\)$`)
+	// sheetDateRe and sheetTimeRe match the "Fecha 7/20/2023" and "Hora 5:46:18 PM" fields:
+	sheetDateRe = regexp.MustCompile(`Fecha\s+(\d{1,2}/\d{1,2}/\d{4})`)
+	sheetTimeRe = regexp.MustCompile(`Hora\s+(\d{1,2}:\d{2}:\d{2}(?:\s*[AP]M)?)`)
+	// sheetItemRe matches the agenda item used as subject, e.g. "Punto 6: ...":
+	sheetItemRe = regexp.MustCompile(`^Punto\s*\d+\s*:\s*(.*)$`)
+	sheetPageRe = regexp.MustCompile(`^\d+$`)
+
+	sectionOptions = map[string]vote.Option{
+		"si":           vote.Yes,
+		"no":           vote.No,
+		"abstencion":   vote.Abstention,
+		"abstenciones": vote.Abstention,
+		"no votan":     vote.NotVoting,
+		"ausente":      vote.Absent,
+		"ausentes":     vote.Absent,
+	}
+)
+
+// VoteTable extracts votes from digital sheets -type "a" documents- where names are
+// listed in a table below a header for each option. The table structure is rebuilt
+// from the character positions of the text layer:
+type VoteTable struct{}
+
+// tableSection is the list of names below an option header:
+type tableSection struct {
+	option vote.Option
+	count  int
+	// names holds the lines of every cell, the lines of a cell are kept together
+	// so names that wrap can be joined:
+	names [][]*table.Line
+}
+
+// Name implements Extractor:
+func (e *VoteTable) Name() string {
+	return VoteTableName
+}
+
+// Extract rebuilds the table of every page and reads the sections:
+func (e *VoteTable) Extract(in *Input) (*document.ExtractionResult, error) {
+	layouts, err := pdf2png.GetPageLayouts(in.Document.PDFPath)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]*table.Table, 0, len(layouts))
+	for _, layout := range layouts {
+		tables = append(tables, table.Detect(layout))
+	}
+
+	record := &vote.Record{
+		Votes:  make([]*vote.Vote, 0),
+		Totals: make(map[vote.Option]int),
+	}
+	evidence := make([]*document.Evidence, 0)
+	sections := make([]*tableSection, 0)
+	var section *tableSection
+	subject := make([]string, 0)
+	inSubject := false
+	// preamble holds the rows before the first section, they're repeated as page headers:
+	preamble := make(map[string]bool)
+	for page, t := range tables {
+		for _, row := range t.Rows {
+			text := row.Text()
+			if match := sectionRe.FindStringSubmatch(text); match != nil {
+				option := sectionOptions[accentReplacer.Replace(strings.ToLower(match[1]))]
+				count, _ := strconv.Atoi(match[2])
+				section = &tableSection{option: option, count: count}
+				sections = append(sections, section)
+				record.Totals[option] = count
+				inSubject = false
+				evidence = append(evidence, &document.Evidence{Field: "totals", Page: page, Source: "table", Raw: text})
+				continue
+			}
+			if match := sheetDateRe.FindStringSubmatch(text); match != nil {
+				record.Date = parseSheetDate(match[1])
+				evidence = append(evidence, &document.Evidence{Field: "date", Page: page, Source: "table", Raw: text})
+			}
+			if match := sheetTimeRe.FindStringSubmatch(text); match != nil {
+				record.Time = parseSheetTime(match[1])
+				evidence = append(evidence, &document.Evidence{Field: "time", Page: page, Source: "table", Raw: text})
+			}
+			if match := sheetItemRe.FindStringSubmatch(text); match != nil && section == nil {
+				subject = append(subject, match[1])
+				inSubject = true
+				continue
+			}
+			if inSubject {
+				subject = append(subject, text)
+				continue
+			}
+			if section == nil {
+				preamble[text] = true
+				continue
+			}
+			// Skip page headers and page numbers:
+			if preamble[text] || sheetPageRe.MatchString(text) {
+				continue
+			}
+			for _, cell := range row.Cells {
+				if !cell.Empty() {
+					section.names = append(section.names, cell.Lines)
+					evidence = append(evidence, &document.Evidence{Field: "votes", Page: page, Source: "table", Raw: cell.Text()})
+				}
+			}
+		}
+	}
+	if len(sections) == 0 {
+		return nil, errNoVotesFound
+	}
+	if len(subject) > 0 {
+		record.Subject = strings.Join(subject, " ")
+		evidence = append(evidence, &document.Evidence{Field: "subject", Source: "table", Raw: record.Subject})
+	}
+
+	confidence := map[string]float64{"votes": 1}
+	for _, s := range sections {
+		names := s.joinWrapped()
+		// Sections that don't match their header count are flagged with a lower confidence:
+		rowConfidence := 1.0
+		if len(names) != s.count {
+			rowConfidence = 0.5
+			confidence["votes"] = 0.5
+		}
+		for _, name := range names {
+			record.Votes = append(record.Votes, &vote.Vote{
+				Name:       name,
+				Option:     s.option,
+				Confidence: rowConfidence,
+			})
+		}
+	}
+	for field, value := range map[string]string{
+		"date":    record.Date,
+		"time":    record.Time,
+		"subject": record.Subject,
+	} {
+		if value != "" {
+			confidence[field] = 1
+		}
+	}
+	return &document.ExtractionResult{
+		Votes:      record,
+		Confidence: confidence,
+		Evidence:   evidence,
+		Info: &document.ResultInfo{
+			Method:    "text",
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
+
+// joinWrapped returns the names of the section. Every line is a name, when there are more
+// lines than the header count the closest lines of a cell are joined as they're wrapped names:
+func (s *tableSection) joinWrapped() []string {
+	cells := make([][]*table.Line, 0, len(s.names))
+	total := 0
+	for _, lines := range s.names {
+		copied := make([]*table.Line, 0, len(lines))
+		for _, l := range lines {
+			line := *l
+			copied = append(copied, &line)
+		}
+		cells = append(cells, copied)
+		total += len(lines)
+	}
+	for ; total > s.count; total-- {
+		cell, index, distance := -1, 0, 0.0
+		for i, lines := range cells {
+			for j := 1; j < len(lines); j++ {
+				d := lines[j].Top - lines[j-1].Top
+				if cell == -1 || d < distance {
+					cell, index, distance = i, j, d
+				}
+			}
+		}
+		if cell == -1 {
+			break
+		}
+		lines := cells[cell]
+		lines[index-1].Text += " " + lines[index].Text
+		lines[index-1].Bottom = lines[index].Bottom
+		cells[cell] = append(lines[:index], lines[index+1:]...)
+	}
+	names := make([]string, 0, total)
+	for _, lines := range cells {
+		for _, l := range lines {
+			names = append(names, l.Text)
+		}
+	}
+	return names
+}
+
+// parseSheetDate converts month/day/year dates, the format used by the voting system,
+// dates that can't be month first are read as day first:
+func parseSheetDate(value string) string {
+	for _, layout := range []string{"1/2/2006", "2/1/2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+	return ""
+}
+
+// parseSheetTime converts 12 or 24 hour times:
+func parseSheetTime(value string) string {
+	for _, layout := range []string{"3:04:05 PM", "3:04:05PM", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.Format("15:04:05")
+		}
+	}
+	return ""
+}
+
+// NewVoteTable initializes the digital vote sheet extractor:
+func NewVoteTable() *VoteTable {
+	return &VoteTable{}
+}
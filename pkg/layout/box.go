@@ -0,0 +1,114 @@
+package layout
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/ocr"
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+)
+
+// Box is a positioned piece of text, positions are relative to the page size:
+type Box struct {
+	Text   string
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+}
+
+// Center returns the center of the box:
+func (b Box) Center() (float64, float64) {
+	return (b.Left + b.Right) / 2, (b.Top + b.Bottom) / 2
+}
+
+// line is a group of boxes on the same line:
+type line struct {
+	text   string
+	top    float64
+	bottom float64
+}
+
+// BoxesFromText converts the text layer boxes of a page:
+func BoxesFromText(textBoxes []pdf2png.TextBox) []Box {
+	boxes := make([]Box, 0, len(textBoxes))
+	for _, b := range textBoxes {
+		boxes = append(boxes, Box{Text: b.Text, Left: b.Left, Top: b.Top, Right: b.Right, Bottom: b.Bottom})
+	}
+	return boxes
+}
+
+// BoxesFromOCR converts the words recognized in an image of the given size in pixels:
+func BoxesFromOCR(words []ocr.Word, width, height int) []Box {
+	boxes := make([]Box, 0, len(words))
+	for _, w := range words {
+		boxes = append(boxes, Box{
+			// Words are separated by spaces when lines are joined:
+			Text:   w.Text + " ",
+			Left:   float64(w.Left) / float64(width),
+			Top:    float64(w.Top) / float64(height),
+			Right:  float64(w.Left+w.Width) / float64(width),
+			Bottom: float64(w.Top+w.Height) / float64(height),
+		})
+	}
+	return boxes
+}
+
+// groupLines groups boxes whose vertical centers overlap, lines are sorted from top to bottom:
+func groupLines(boxes []Box) []*line {
+	sort.Slice(boxes, func(i, j int) bool {
+		_, yi := boxes[i].Center()
+		_, yj := boxes[j].Center()
+		return yi < yj
+	})
+	groups := make([][]Box, 0)
+	for _, b := range boxes {
+		_, y := b.Center()
+		if n := len(groups); n > 0 {
+			first := groups[n-1][0]
+			if y >= first.Top && y <= first.Bottom {
+				groups[n-1] = append(groups[n-1], b)
+				continue
+			}
+		}
+		groups = append(groups, []Box{b})
+	}
+	lines := make([]*line, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Left < group[j].Left
+		})
+		l := &line{top: group[0].Top, bottom: group[0].Bottom}
+		var text strings.Builder
+		for i, b := range group {
+			// Text runs may split words, a space is only added when there's a gap:
+			if i > 0 && b.Left-group[i-1].Right > (b.Bottom-b.Top)/4 {
+				text.WriteString(" ")
+			}
+			text.WriteString(b.Text)
+			if b.Top < l.top {
+				l.top = b.Top
+			}
+			if b.Bottom > l.bottom {
+				l.bottom = b.Bottom
+			}
+		}
+		l.text = strings.Join(strings.Fields(text.String()), " ")
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// joinLines returns the text of every line, lines starting closer than distance
+// from the previous one are joined:
+func joinLines(lines []*line, distance float64) []string {
+	values := make([]string, 0, len(lines))
+	for i, l := range lines {
+		if i > 0 && l.top-lines[i-1].top < distance {
+			values[len(values)-1] += " " + l.text
+			continue
+		}
+		values = append(values, l.text)
+	}
+	return values
+}
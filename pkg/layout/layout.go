@@ -0,0 +1,227 @@
+package layout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// Fields that regions can be mapped to:
+const (
+	FieldChamber = "chamber"
+	FieldDate    = "date"
+	FieldTime    = "time"
+	FieldSubject = "subject"
+	FieldVotes   = "votes"
+)
+
+var fields = map[string]bool{
+	FieldChamber: true, FieldDate: true, FieldTime: true, FieldSubject: true, FieldVotes: true,
+}
+
+var (
+	errUnknownField  = errors.New("unknown field")
+	errInvalidRegion = errors.New("invalid region")
+	errMissingOption = errors.New("votes regions require an option")
+)
+
+// Template describes the fixed layout of a document type
+// Templates are JSON files, one per document type, see templates/a.json:
+type Template struct {
+	// Type is the document type the template applies to:
+	Type string `json:"type"`
+	// Description is a free text description of the layout:
+	Description string `json:"description,omitempty"`
+	// Regions are the named areas to read:
+	Regions []*Region `json:"regions"`
+
+	// fileName is the file the template was loaded from:
+	fileName string
+}
+
+// Region is a named area of a page
+// Coordinates are relative to the page size -0 to 1- with the origin at the top left corner:
+type Region struct {
+	Name  string `json:"name"`
+	Field string `json:"field"`
+	// Option is the vote option of the names found in a votes region:
+	Option vote.Option `json:"option,omitempty"`
+	// Page is the page index, starting at 0. AllPages reads the region on every page:
+	Page     int     `json:"page"`
+	AllPages bool    `json:"all_pages,omitempty"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	// Columns splits the region in columns of the same width, each line of a column is a value:
+	Columns int `json:"columns,omitempty"`
+	// JoinLines joins lines that are closer than this distance, for names that wrap:
+	JoinLines float64 `json:"join_lines,omitempty"`
+	// Pattern is an optional regular expression, the first group -or the whole match- is the value:
+	Pattern string `json:"pattern,omitempty"`
+	// Format is the Go time layout of date and time values, e.g. "1/2/2006" or "3:04:05 PM":
+	Format string `json:"format,omitempty"`
+	// Start is an optional regular expression matching the header line the region starts below,
+	// the header is searched within the region so its height can vary between documents:
+	Start string `json:"start,omitempty"`
+	// End is an optional regular expression matching the first line below the region,
+	// the region extends to its bottom when no line matches:
+	End string `json:"end,omitempty"`
+
+	pattern *regexp.Regexp
+	start   *regexp.Regexp
+	end     *regexp.Regexp
+}
+
+// Load reads every template in a directory, a missing directory has no templates:
+func Load(dir string) (map[string]*Template, error) {
+	templates := make(map[string]*Template)
+	fileNames, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, fileName := range fileNames {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		t := &Template{fileName: fileName}
+		if err := json.Unmarshal(data, t); err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := templates[t.Type]; ok {
+			return nil, fmt.Errorf("%s: duplicated template for type %q", fileName, t.Type)
+		}
+		templates[t.Type] = t
+	}
+	return templates, nil
+}
+
+// Validate checks the fields, coordinates and patterns of every region:
+func (t *Template) Validate() error {
+	if t.Type == "" {
+		return fmt.Errorf("%s: template type is empty", t.fileName)
+	}
+	for _, r := range t.Regions {
+		if !fields[r.Field] {
+			return fmt.Errorf("%s: region %q: %w: %s", t.fileName, r.Name, errUnknownField, r.Field)
+		}
+		if r.Field == FieldVotes && r.Option == "" {
+			return fmt.Errorf("%s: region %q: %w", t.fileName, r.Name, errMissingOption)
+		}
+		if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 || r.X+r.Width > 1 || r.Y+r.Height > 1 || r.Page < 0 || r.Columns < 0 {
+			return fmt.Errorf("%s: region %q: %w", t.fileName, r.Name, errInvalidRegion)
+		}
+		for _, p := range []struct {
+			expr   string
+			target **regexp.Regexp
+		}{{r.Pattern, &r.pattern}, {r.Start, &r.start}, {r.End, &r.end}} {
+			if p.expr == "" {
+				continue
+			}
+			compiled, err := regexp.Compile(p.expr)
+			if err != nil {
+				return fmt.Errorf("%s: region %q: %w", t.fileName, r.Name, err)
+			}
+			*p.target = compiled
+		}
+	}
+	return nil
+}
+
+// Values returns the values found in the region, boxes are the text boxes of the page
+// Every line is a value for votes regions, other fields are joined in a single value:
+func (r *Region) Values(boxes []Box) []string {
+	top, bottom, ok := r.bounds(boxes)
+	if !ok {
+		return []string{}
+	}
+	columns := r.Columns
+	if columns == 0 {
+		columns = 1
+	}
+	columnWidth := r.Width / float64(columns)
+	values := make([]string, 0)
+	for i := 0; i < columns; i++ {
+		left := r.X + float64(i)*columnWidth
+		inside := make([]Box, 0)
+		for _, b := range boxes {
+			x, y := b.Center()
+			if x >= left && x < left+columnWidth && y >= top && y < bottom {
+				inside = append(inside, b)
+			}
+		}
+		values = append(values, joinLines(groupLines(inside), r.JoinLines)...)
+	}
+	if r.Field != FieldVotes {
+		values = []string{strings.Join(values, " ")}
+	}
+	matched := make([]string, 0, len(values))
+	for _, value := range values {
+		value = r.match(value)
+		if value != "" {
+			matched = append(matched, value)
+		}
+	}
+	return matched
+}
+
+// bounds returns the vertical range of the region, anchored to the Start and End lines
+// when set. ok is false when the Start line isn't found:
+func (r *Region) bounds(boxes []Box) (float64, float64, bool) {
+	top, bottom := r.Y, r.Y+r.Height
+	if r.start == nil && r.end == nil {
+		return top, bottom, true
+	}
+	inside := make([]Box, 0)
+	for _, b := range boxes {
+		if _, y := b.Center(); y >= top && y < bottom {
+			inside = append(inside, b)
+		}
+	}
+	lines := groupLines(inside)
+	i := 0
+	if r.start != nil {
+		for i < len(lines) && !r.start.MatchString(lines[i].text) {
+			i++
+		}
+		if i == len(lines) {
+			return 0, 0, false
+		}
+		top = lines[i].bottom
+		i++
+	}
+	if r.end != nil {
+		for ; i < len(lines); i++ {
+			if r.end.MatchString(lines[i].text) {
+				bottom = lines[i].top
+				break
+			}
+		}
+	}
+	return top, bottom, true
+}
+
+// match applies the pattern to a value:
+func (r *Region) match(value string) string {
+	if r.pattern == nil {
+		return strings.TrimSpace(value)
+	}
+	match := r.pattern.FindStringSubmatch(value)
+	switch len(match) {
+	case 0:
+		return ""
+	case 1:
+		return strings.TrimSpace(match[0])
+	}
+	return strings.TrimSpace(match[1])
+}
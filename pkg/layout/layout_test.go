@@ -0,0 +1,60 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sheet returns the boxes of a voting sheet with a header per section followed by
+// its names, one per line:
+func sheet(sections map[string]int, order []string) []Box {
+	boxes := make([]Box, 0)
+	y := 0.3
+	add := func(text string) {
+		boxes = append(boxes, Box{Text: text, Left: 0.06, Top: y, Right: 0.2, Bottom: y + 0.01})
+		y += 0.02
+	}
+	for _, header := range order {
+		add(fmt.Sprintf("%s( %d )", header, sections[header]))
+		for i := 0; i < sections[header]; i++ {
+			add(fmt.Sprintf("%s %d", header, i))
+		}
+	}
+	return boxes
+}
+
+func TestRegionValuesAnchored(t *testing.T) {
+	const end = `^(Si|No|No votan)\(`
+	tmpl := &Template{Type: "a", Regions: []*Region{
+		{Name: "si", Field: FieldVotes, Option: "si", X: 0.05, Y: 0.28, Width: 0.75, Height: 0.7, Start: `^Si\(`, End: end},
+		{Name: "no", Field: FieldVotes, Option: "no", X: 0.05, Y: 0.28, Width: 0.75, Height: 0.7, Start: `^No\(`, End: end},
+		{Name: "no_votan", Field: FieldVotes, Option: "no_vota", X: 0.05, Y: 0.28, Width: 0.75, Height: 0.7, Start: `^No votan\(`, End: end},
+	}}
+	if err := tmpl.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	order := []string{"Si", "No", "No votan"}
+	for _, sections := range []map[string]int{
+		{"Si": 20, "No": 5, "No votan": 2},
+		{"Si": 3, "No": 20, "No votan": 4},
+		{"Si": 0, "No": 10, "No votan": 0},
+	} {
+		boxes := sheet(sections, order)
+		for i, region := range tmpl.Regions {
+			values := region.Values(boxes)
+			if len(values) != sections[order[i]] {
+				t.Errorf("%v: region %s has %d values, want %d: %v", sections, region.Name, len(values), sections[order[i]], values)
+			}
+			for _, value := range values {
+				if value[:strings.LastIndex(value, " ")] != order[i] {
+					t.Errorf("%v: region %s has a name of another section: %q", sections, region.Name, value)
+				}
+			}
+		}
+	}
+	// A missing header has no values:
+	if values := tmpl.Regions[2].Values(sheet(map[string]int{"Si": 2}, []string{"Si"})); len(values) != 0 {
+		t.Errorf("expected no values without the header, got %v", values)
+	}
+}
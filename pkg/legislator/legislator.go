@@ -0,0 +1,281 @@
+package legislator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+const (
+	// MinScore is the minimum score of an automatic match:
+	MinScore = 0.85
+	// minMargin is the minimum difference between the best and the second best match,
+	// closer matches are ambiguous:
+	minMargin = 0.05
+	// minCandidateScore is the minimum score of the candidates suggested for review:
+	minCandidateScore = 0.5
+	// maxCandidates is the amount of candidates suggested for review:
+	maxCandidates = 3
+	// minTokenScore is the minimum similarity of two name tokens to be considered the same:
+	minTokenScore = 0.75
+	// extraTokenPenalty is subtracted per token that only appears in one of the names,
+	// rosters usually have full names while vote sheets omit second names:
+	extraTokenPenalty = 0.05
+	// singleTokenPenalty scales matches based on a single token, like a surname alone:
+	singleTokenPenalty = 0.8
+	// maxAbbreviation is the maximum length of an abbreviated name token:
+	maxAbbreviation = 2
+	// aliasSeparator separates the aliases in the CSV column:
+	aliasSeparator = "|"
+)
+
+var errMissingColumn = errors.New("missing CSV column")
+
+// Person is a legislator:
+type Person struct {
+	// ID is the stable identifier used to join votes:
+	ID string `json:"id"`
+	// Name is the canonical name:
+	Name string `json:"name"`
+	// Aliases are other spellings found in vote documents:
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// Names returns the canonical name followed by the aliases:
+func (p *Person) Names() []string {
+	return append([]string{p.Name}, p.Aliases...)
+}
+
+// AddAlias adds an alias, names equivalent to an existing one are ignored:
+func (p *Person) AddAlias(alias string) bool {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return false
+	}
+	for _, name := range p.Names() {
+		if vote.NormalizeName(name) == vote.NormalizeName(alias) {
+			return false
+		}
+	}
+	p.Aliases = append(p.Aliases, alias)
+	return true
+}
+
+// Slug returns an ID for a name: its normalized tokens joined with dashes, as
+// tokens are sorted "PEREZ, Juan" and "Juan Pérez" share the same ID:
+func Slug(name string) string {
+	return strings.Join(tokens(name), "-")
+}
+
+// ReadCSV reads a roster, the header must have the "name" column and optionally
+// "id" -derived from the name when empty- and "aliases", separated by "|":
+func ReadCSV(r io.Reader) ([]*Person, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("%w: name", errMissingColumn)
+	}
+	value := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	persons := make([]*Person, 0)
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		p := &Person{
+			ID:   value(record, "id"),
+			Name: value(record, "name"),
+		}
+		if p.Name == "" {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: empty name", line)
+		}
+		if p.ID == "" {
+			p.ID = Slug(p.Name)
+		}
+		if seen[p.ID] {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: duplicated ID %s", line, p.ID)
+		}
+		seen[p.ID] = true
+		for _, alias := range strings.Split(value(record, "aliases"), aliasSeparator) {
+			p.AddAlias(alias)
+		}
+		persons = append(persons, p)
+	}
+	return persons, nil
+}
+
+// Match is a person matched to a name:
+type Match struct {
+	Person *Person `json:"-"`
+	// PersonID is the ID of the matched person:
+	PersonID string `json:"person_id"`
+	// Score is the similarity between 0 and 1:
+	Score float64 `json:"score"`
+}
+
+// entry is a name of a person, prepared for matching:
+type entry struct {
+	person *Person
+	tokens []string
+	// normalized is the output of vote.NormalizeName:
+	normalized string
+}
+
+// Matcher resolves names found in documents to persons:
+type Matcher struct {
+	entries []*entry
+}
+
+// NewMatcher prepares the names and aliases of the persons for matching:
+func NewMatcher(persons []*Person) *Matcher {
+	m := &Matcher{}
+	for _, p := range persons {
+		for _, name := range p.Names() {
+			m.entries = append(m.entries, &entry{
+				person:     p,
+				tokens:     tokens(name),
+				normalized: vote.NormalizeName(name),
+			})
+		}
+	}
+	return m
+}
+
+// Resolve returns the matching person of a name, or nil when there's no reliable match
+// along with the best candidates for a manual review:
+func (m *Matcher) Resolve(name string) (*Match, []*Match) {
+	nameTokens := tokens(name)
+	normalized := vote.NormalizeName(name)
+	best := make(map[string]*Match)
+	for _, e := range m.entries {
+		score := 1.0
+		if e.normalized != normalized {
+			score = similarity(nameTokens, e.tokens)
+		}
+		if current, ok := best[e.person.ID]; !ok || score > current.Score {
+			best[e.person.ID] = &Match{Person: e.person, PersonID: e.person.ID, Score: score}
+		}
+	}
+	matches := make([]*Match, 0, len(best))
+	for _, match := range best {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].PersonID < matches[j].PersonID
+	})
+	if len(matches) > 0 && matches[0].Score >= MinScore {
+		if len(matches) == 1 || matches[0].Score-matches[1].Score >= minMargin {
+			return matches[0], nil
+		}
+	}
+	candidates := make([]*Match, 0, maxCandidates)
+	for _, match := range matches {
+		if match.Score < minCandidateScore || len(candidates) == maxCandidates {
+			break
+		}
+		candidates = append(candidates, match)
+	}
+	return nil, candidates
+}
+
+// tokens returns the lowercase tokens of a name without accents or punctuation:
+func tokens(name string) []string {
+	return strings.Fields(vote.NormalizeName(name))
+}
+
+// similarity compares two names token by token regardless of their order: every token of the
+// shorter name is paired with the most similar unused token of the other one. Tokens only present
+// in the longer name are penalized, so "AMARILLA, DIONISIO" matches "Dionisio Amarilla Guerrero":
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	used := make([]bool, len(b))
+	total := 0.0
+	for _, tokenA := range a {
+		bestScore, bestIndex := 0.0, -1
+		for i, tokenB := range b {
+			if used[i] {
+				continue
+			}
+			if score := tokenSimilarity(tokenA, tokenB); score > bestScore {
+				bestScore, bestIndex = score, i
+			}
+		}
+		if bestIndex < 0 || bestScore < minTokenScore {
+			return 0
+		}
+		used[bestIndex] = true
+		total += bestScore
+	}
+	score := total/float64(len(a)) - extraTokenPenalty*float64(len(b)-len(a))
+	if len(a) == 1 {
+		score *= singleTokenPenalty
+	}
+	return max(score, 0)
+}
+
+// tokenSimilarity compares two tokens, an abbreviation -"j" or "ma"- matches the tokens it starts:
+func tokenSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if len(a) <= maxAbbreviation && strings.HasPrefix(b, a) || len(b) <= maxAbbreviation && strings.HasPrefix(a, b) {
+		return 0.9
+	}
+	longest := max(len([]rune(a)), len([]rune(b)))
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between two strings:
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
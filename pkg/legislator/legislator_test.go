@@ -0,0 +1,154 @@
+package legislator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		min, max float64
+	}{
+		{"juan perez", "juan perez", 1, 1},
+		{"perez juan", "juan perez", 1, 1},
+		// One extra token in the longer name:
+		{"dionisio amarilla", "dionisio amarilla guerrero", 0.95, 0.95},
+		// Abbreviations:
+		{"j perez", "juan perez", 0.95, 0.95},
+		// A typo:
+		{"juan peres", "juan perez", 0.9, 0.95},
+		// A single token is penalized:
+		{"perez", "juan perez", 0.7, 0.8},
+		{"juan perez", "ana gomez", 0, 0},
+		{"", "juan perez", 0, 0},
+	}
+	for _, tt := range tests {
+		got := similarity(strings.Fields(tt.a), strings.Fields(tt.b))
+		if got < tt.min-1e-9 || got > tt.max+1e-9 {
+			t.Errorf("similarity(%q, %q) = %.3f, want between %.3f and %.3f", tt.a, tt.b, got, tt.min, tt.max)
+		}
+		if reverse := similarity(strings.Fields(tt.b), strings.Fields(tt.a)); reverse != got {
+			t.Errorf("similarity(%q, %q) = %.3f is not symmetric: %.3f", tt.a, tt.b, got, reverse)
+		}
+	}
+}
+
+func TestSlug(t *testing.T) {
+	for _, name := range []string{"PÉREZ, Juan", "Juan Pérez", "  juan   perez "} {
+		if got := Slug(name); got != "juan-perez" {
+			t.Errorf("Slug(%q) = %q, want juan-perez", name, got)
+		}
+	}
+}
+
+func TestMatcherResolve(t *testing.T) {
+	matcher := NewMatcher([]*Person{
+		{ID: "celeste-amarilla", Name: "Celeste Amarilla Vda. de Boccia"},
+		{ID: "dionisio-amarilla", Name: "Dionisio Amarilla Guerrero"},
+		{ID: "lider-amarilla", Name: "Líder Amarilla"},
+		{ID: "arnaldo-samaniego", Name: "Arnaldo Samaniego"},
+		{ID: "lilian-samaniego", Name: "Lilian Samaniego"},
+		{ID: "esperanza-martinez", Name: "Esperanza Martínez", Aliases: []string{"MARTINEZ, ESPERANZA"}},
+		{ID: "carlos-nunez", Name: "Carlos Núñez"},
+		{ID: "basilio-nunez", Name: "Basilio Núñez"},
+	})
+	tests := []struct {
+		name string
+		// id is the expected person, empty when the name must not be resolved:
+		id string
+		// candidate is expected among the candidates of unresolved names:
+		candidate string
+	}{
+		{name: "Arnaldo Samaniego", id: "arnaldo-samaniego"},
+		{name: "SAMANIEGO, Arnaldo", id: "arnaldo-samaniego"},
+		{name: "Arnaldo Samaniega", id: "arnaldo-samaniego"},
+		{name: "A. Samaniego", id: "arnaldo-samaniego"},
+		{name: "Lider Amarilla", id: "lider-amarilla"},
+		{name: "AMARILLA, DIONISIO", id: "dionisio-amarilla"},
+		{name: "Celeste Amarilla", id: "celeste-amarilla"},
+		{name: "Martinez Esperanza", id: "esperanza-martinez"},
+		{name: "Carlos Nuñez", id: "carlos-nunez"},
+		// A surname shared by two persons is ambiguous:
+		{name: "Samaniego", candidate: "arnaldo-samaniego"},
+		{name: "Núñez", candidate: "basilio-nunez"},
+		// Unknown names aren't matched to the closest person:
+		{name: "Juan Pérez"},
+		{name: "Rafael Filizzola"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, candidates := matcher.Resolve(tt.name)
+			if tt.id == "" {
+				if match != nil {
+					t.Fatalf("expected no match, got %s (%.3f)", match.PersonID, match.Score)
+				}
+				if tt.candidate == "" {
+					return
+				}
+				for _, candidate := range candidates {
+					if candidate.PersonID == tt.candidate {
+						return
+					}
+				}
+				t.Fatalf("expected %s among the candidates, got %v", tt.candidate, candidateIDs(candidates))
+			}
+			if match == nil {
+				t.Fatalf("expected %s, got no match, candidates: %v", tt.id, candidateIDs(candidates))
+			}
+			if match.PersonID != tt.id {
+				t.Errorf("expected %s, got %s (%.3f)", tt.id, match.PersonID, match.Score)
+			}
+			if match.Score < MinScore {
+				t.Errorf("score %.3f is below the minimum", match.Score)
+			}
+		})
+	}
+}
+
+func candidateIDs(matches []*Match) []string {
+	ids := make([]string, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.PersonID)
+	}
+	return ids
+}
+
+func TestReadCSV(t *testing.T) {
+	persons, err := ReadCSV(strings.NewReader("id,name,aliases\n,Juan Pérez,PEREZ Juan|J. Pérez\nana,Ana Gómez,\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(persons) != 2 {
+		t.Fatalf("expected 2 persons, got %d", len(persons))
+	}
+	if persons[0].ID != "juan-perez" {
+		t.Errorf("expected an ID derived from the name, got %q", persons[0].ID)
+	}
+	// "PEREZ Juan" is equivalent to the name:
+	if len(persons[0].Aliases) != 1 || persons[0].Aliases[0] != "J. Pérez" {
+		t.Errorf("unexpected aliases %v", persons[0].Aliases)
+	}
+	if persons[1].ID != "ana" {
+		t.Errorf("unexpected ID %q", persons[1].ID)
+	}
+	for _, input := range []string{
+		"id,aliases\nx,y\n",
+		"id,name\n,\n",
+		"id,name\na,Juan\na,Ana\n",
+	} {
+		if _, err := ReadCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("expected an error reading %q", input)
+		}
+	}
+}
+
+func TestOrganizationID(t *testing.T) {
+	party, bloc := OrganizationID(ClassificationParty, "ANR Colorado"), OrganizationID(ClassificationBloc, "ANR Colorado")
+	if party != "party:anr-colorado" {
+		t.Errorf("unexpected ID %q", party)
+	}
+	if party == bloc {
+		t.Errorf("a party and a bloc with the same name share the ID %q", party)
+	}
+}
@@ -0,0 +1,135 @@
+package legislator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// Organization classifications:
+const (
+	// ClassificationParty is a political party:
+	ClassificationParty = "party"
+	// ClassificationBloc is a parliamentary bloc -"bancada"-:
+	ClassificationBloc = "bloc"
+)
+
+// dateLayout is the layout of the membership dates:
+const dateLayout = "2006-01-02"
+
+// Membership is a time bounded membership of a person in an organization:
+type Membership struct {
+	PersonID     string `json:"person_id"`
+	Organization string `json:"organization"`
+	// Classification is ClassificationParty or ClassificationBloc:
+	Classification string `json:"classification"`
+	// Role is the role in the organization -e.g. "miembro", "jefe de bancada"-:
+	Role string `json:"role,omitempty"`
+	// Start and End use the YYYY-MM-DD format, an empty End means the membership is current:
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+}
+
+// Active reports if the membership is effective at a YYYY-MM-DD date, both ends are inclusive:
+func (m *Membership) Active(date string) bool {
+	return m.Start <= date && (m.End == "" || date <= m.End)
+}
+
+// Key identifies a membership, importing a membership with the same key updates it:
+func (m *Membership) Key() string {
+	return strings.Join([]string{m.PersonID, m.Organization, m.Classification, m.Role, m.Start}, "|")
+}
+
+// OrganizationID returns the ID of an organization, e.g. "party:anr". The classification is
+// included so a party and a bloc with the same name are different organizations, unlike Slug
+// the token order of the name is kept:
+func OrganizationID(classification, name string) string {
+	return classification + ":" + strings.ReplaceAll(vote.FoldName(name), " ", "-")
+}
+
+// Affiliation returns the organization of the given classification a person belonged to at a
+// YYYY-MM-DD date, empty when there's none. Overlapping memberships resolve to the latest one:
+func Affiliation(memberships []*Membership, personID, classification, date string) string {
+	var current *Membership
+	for _, m := range memberships {
+		if m.PersonID != personID || m.Classification != classification || !m.Active(date) {
+			continue
+		}
+		if current == nil || m.Start > current.Start {
+			current = m
+		}
+	}
+	if current == nil {
+		return ""
+	}
+	return current.Organization
+}
+
+// ReadMembershipsCSV reads memberships, the header must have the "person_id", "organization",
+// "classification" and "start" columns and optionally "role" and "end". Dates use the YYYY-MM-DD format:
+func ReadMembershipsCSV(r io.Reader) ([]*Membership, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, column := range header {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range []string{"person_id", "organization", "classification", "start"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: %s", errMissingColumn, column)
+		}
+	}
+	value := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	memberships := make([]*Membership, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		m := &Membership{
+			PersonID:       value(record, "person_id"),
+			Organization:   value(record, "organization"),
+			Classification: strings.ToLower(value(record, "classification")),
+			Role:           value(record, "role"),
+			Start:          value(record, "start"),
+			End:            value(record, "end"),
+		}
+		if m.PersonID == "" || m.Organization == "" {
+			return nil, fmt.Errorf("line %d: empty person_id or organization", line)
+		}
+		if m.Classification != ClassificationParty && m.Classification != ClassificationBloc {
+			return nil, fmt.Errorf("line %d: classification must be %q or %q", line, ClassificationParty, ClassificationBloc)
+		}
+		if _, err := time.Parse(dateLayout, m.Start); err != nil {
+			return nil, fmt.Errorf("line %d: invalid start %q", line, m.Start)
+		}
+		if m.End != "" {
+			if _, err := time.Parse(dateLayout, m.End); err != nil {
+				return nil, fmt.Errorf("line %d: invalid end %q", line, m.End)
+			}
+			if m.End < m.Start {
+				return nil, fmt.Errorf("line %d: ends before it starts", line)
+			}
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, nil
+}
@@ -0,0 +1,198 @@
+package marks
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	_ "image/png"
+	"os"
+)
+
+const (
+	// darkThreshold is the gray level below which a pixel is ink:
+	darkThreshold = 160
+	// minLineLength is the length of a ruling line relative to the image size:
+	minLineLength = 0.3
+	// skewTolerance is the amount of pixels a line can drift because of a skewed scan:
+	skewTolerance = 6
+	// cellMargin is the part of every side of a cell that's ignored, so borders aren't counted as marks:
+	cellMargin = 0.2
+	// fullCell is the ink ratio of a cell considered completely marked, an X covers about 5% of a cell:
+	fullCell = 0.08
+)
+
+var (
+	errGridNotFound = errors.New("table grid not found")
+)
+
+// Span is a range of pixels between two ruling lines:
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Center returns the middle of the span:
+func (s Span) Center() int {
+	return (s.Start + s.End) / 2
+}
+
+// Contains reports if a position is inside the span:
+func (s Span) Contains(p int) bool {
+	return p >= s.Start && p < s.End
+}
+
+// Grid is the table located in an image, Scores holds the filled score of every cell
+// from 0 -empty- to 1, indexed by row and column:
+type Grid struct {
+	Rows    []Span      `json:"rows"`
+	Columns []Span      `json:"columns"`
+	Scores  [][]float64 `json:"scores"`
+}
+
+// Row returns the index of the row containing y, -1 if there's none:
+func (g *Grid) Row(y int) int {
+	for i, r := range g.Rows {
+		if r.Contains(y) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Column returns the index of the column containing x, -1 if there's none:
+func (g *Grid) Column(x int) int {
+	for i, c := range g.Columns {
+		if c.Contains(x) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Detect locates the ruled table of an image and scores every cell:
+func Detect(imagePath string) (*Grid, error) {
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return DetectImage(img)
+}
+
+// DetectImage locates the ruled table of an image and scores every cell
+// Ruling lines are the rows and columns with a long run of ink, the mask is dilated
+// across the line direction so lines of skewed scans are still continuous:
+func DetectImage(img image.Image) (*Grid, error) {
+	ink := inkMask(img)
+	if len(ink) == 0 || len(ink[0]) == 0 {
+		return nil, errGridNotFound
+	}
+	width, height := len(ink[0]), len(ink)
+	horizontal := lines(height, width, func(y, x int) bool {
+		for d := -skewTolerance; d <= skewTolerance; d++ {
+			if y+d >= 0 && y+d < height && ink[y+d][x] {
+				return true
+			}
+		}
+		return false
+	})
+	vertical := lines(width, height, func(x, y int) bool {
+		for d := -skewTolerance; d <= skewTolerance; d++ {
+			if x+d >= 0 && x+d < width && ink[y][x+d] {
+				return true
+			}
+		}
+		return false
+	})
+	if len(horizontal) < 2 || len(vertical) < 2 {
+		return nil, errGridNotFound
+	}
+	g := &Grid{
+		Rows:    spans(horizontal),
+		Columns: spans(vertical),
+	}
+	g.Scores = make([][]float64, len(g.Rows))
+	for i, r := range g.Rows {
+		g.Scores[i] = make([]float64, len(g.Columns))
+		for j, c := range g.Columns {
+			g.Scores[i][j] = score(ink, r, c)
+		}
+	}
+	return g, nil
+}
+
+// inkMask returns the dark pixels of an image, indexed by row and column:
+func inkMask(img image.Image) [][]bool {
+	bounds := img.Bounds()
+	mask := make([][]bool, bounds.Dy())
+	for y := range mask {
+		mask[y] = make([]bool, bounds.Dx())
+		for x := range mask[y] {
+			gray := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			mask[y][x] = gray.Y < darkThreshold
+		}
+	}
+	return mask
+}
+
+// lines returns the centers of the lines along one direction, a line is a position whose
+// longest ink run is at least minLineLength of the length. Adjacent positions are merged:
+func lines(count, length int, dark func(position, offset int) bool) []int {
+	centers := make([]int, 0)
+	start := -1
+	for p := 0; p <= count; p++ {
+		isLine := false
+		if p < count {
+			run, longest := 0, 0
+			for o := 0; o < length; o++ {
+				if dark(p, o) {
+					run++
+					longest = max(longest, run)
+					continue
+				}
+				run = 0
+			}
+			isLine = float64(longest) >= float64(length)*minLineLength
+		}
+		switch {
+		case isLine && start == -1:
+			start = p
+		case !isLine && start != -1:
+			centers = append(centers, (start+p-1)/2)
+			start = -1
+		}
+	}
+	return centers
+}
+
+// spans returns the ranges between consecutive lines:
+func spans(centers []int) []Span {
+	result := make([]Span, 0, len(centers)-1)
+	for i := 1; i < len(centers); i++ {
+		result = append(result, Span{Start: centers[i-1], End: centers[i]})
+	}
+	return result
+}
+
+// score returns the filled score of a cell, the ink ratio of its inner area scaled by fullCell:
+func score(ink [][]bool, row, column Span) float64 {
+	marginY := int(float64(row.End-row.Start) * cellMargin)
+	marginX := int(float64(column.End-column.Start) * cellMargin)
+	dark, total := 0, 0
+	for y := row.Start + marginY; y < row.End-marginY; y++ {
+		for x := column.Start + marginX; x < column.End-marginX; x++ {
+			total++
+			if ink[y][x] {
+				dark++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return min(1, float64(dark)/float64(total)/fullCell)
+}
@@ -0,0 +1,97 @@
+package marks
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// minMarkScore is the score the scanned table extractor requires to take a cell as marked:
+const minMarkScore = 0.3
+
+// testSheet draws a 3x3 ruled grid with 2 pixel lines at 50, 100, 150 and 200 vertically and
+// 50, 150, 250 and 350 horizontally, the cell at row 1 and column 2 is marked with an X
+// and the cell at row 0 and column 0 has a small dot:
+func testSheet() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	black := color.Gray{Y: 0}
+	for _, y := range []int{50, 100, 150, 200} {
+		for x := 50; x <= 351; x++ {
+			img.SetGray(x, y, black)
+			img.SetGray(x, y+1, black)
+		}
+	}
+	for _, x := range []int{50, 150, 250, 350} {
+		for y := 50; y <= 201; y++ {
+			img.SetGray(x, y, black)
+			img.SetGray(x+1, y, black)
+		}
+	}
+	// The X, 3 pixels thick:
+	for x := 260; x <= 340; x++ {
+		y := 105 + (x-260)/2
+		for d := 0; d < 3; d++ {
+			img.SetGray(x, y+d, black)
+			img.SetGray(x, 145-(x-260)/2-d, black)
+		}
+	}
+	for y := 74; y < 77; y++ {
+		for x := 99; x < 102; x++ {
+			img.SetGray(x, y, black)
+		}
+	}
+	return img
+}
+
+func TestDetectImage(t *testing.T) {
+	g, err := DetectImage(testSheet())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantRows := []Span{{50, 100}, {100, 150}, {150, 200}}
+	wantColumns := []Span{{50, 150}, {150, 250}, {250, 350}}
+	if len(g.Rows) != len(wantRows) || len(g.Columns) != len(wantColumns) {
+		t.Fatalf("rows = %v, columns = %v, want %v and %v", g.Rows, g.Columns, wantRows, wantColumns)
+	}
+	for i := range wantRows {
+		if g.Rows[i] != wantRows[i] {
+			t.Errorf("row %d = %v, want %v", i, g.Rows[i], wantRows[i])
+		}
+	}
+	for i := range wantColumns {
+		if g.Columns[i] != wantColumns[i] {
+			t.Errorf("column %d = %v, want %v", i, g.Columns[i], wantColumns[i])
+		}
+	}
+	for i, row := range g.Scores {
+		for j, score := range row {
+			if marked := i == 1 && j == 2; marked != (score >= minMarkScore) {
+				t.Errorf("cell %d,%d has score %.2f, marked: %v", i, j, score, marked)
+			}
+		}
+	}
+	if g.Row(120) != 1 || g.Column(300) != 2 || g.Row(20) != -1 || g.Column(380) != -1 {
+		t.Errorf("unexpected cell lookup: row(120) = %d, column(300) = %d", g.Row(120), g.Column(300))
+	}
+}
+
+func TestDetectImageNoGrid(t *testing.T) {
+	for _, img := range []image.Image{
+		image.NewGray(image.Rect(0, 0, 100, 0)),
+		image.NewGray(image.Rect(0, 0, 0, 100)),
+		// A blank page:
+		image.NewGray(image.Rect(0, 0, 100, 100)),
+	} {
+		gray := img.(*image.Gray)
+		for i := range gray.Pix {
+			gray.Pix[i] = 255
+		}
+		if _, err := DetectImage(img); !errors.Is(err, errGridNotFound) {
+			t.Errorf("%v: error = %v, want %v", img.Bounds(), err, errGridNotFound)
+		}
+	}
+}
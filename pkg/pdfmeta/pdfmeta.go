@@ -0,0 +1,30 @@
+package pdfmeta
+
+// Metadata holds the PDF properties recorded when a document is ingested:
+type Metadata struct {
+	// FileSize is the size of the PDF file in bytes:
+	FileSize int64 `json:"file_size"`
+	// SHA256 is the hash of the PDF file:
+	SHA256 string `json:"sha256"`
+	// Info is the document information dictionary -Title, Author, Creator, Producer, etc.-:
+	Info map[string]string `json:"info"`
+	// XMP holds the XMP metadata properties -creation/modification dates, creator tool, producer-:
+	XMP map[string]string `json:"xmp,omitempty"`
+	// PageCount is the amount of pages:
+	PageCount int `json:"page_count"`
+	// Pages holds the size and text layer information of every page:
+	Pages []PageInfo `json:"pages"`
+	// Texts is the text layer of every page, it's not persisted:
+	Texts []string `json:"-"`
+}
+
+// PageInfo describes a single page:
+type PageInfo struct {
+	// Width and Height are expressed in points:
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	// TextChars is the amount of non space characters in the text layer:
+	TextChars int `json:"text_chars"`
+	// HasText is set when the page has a text layer, scanned pages don't:
+	HasText bool `json:"has_text"`
+}
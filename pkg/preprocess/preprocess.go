@@ -0,0 +1,408 @@
+package preprocess
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	// inkThreshold is the gray level below which a pixel is ink when measuring the page:
+	inkThreshold = 128
+	// maxSkew is the largest skew angle corrected, in degrees:
+	maxSkew = 5.0
+	// skewStep is the precision of the skew angle, in degrees:
+	skewStep = 0.1
+	// sampleStep is the distance in pixels between the ink samples used to measure the page:
+	sampleStep = 2
+	// orientationRatio is how many more gaps the column profile needs to consider the page rotated 90°:
+	orientationRatio = 1.5
+	// minLineInk is the amount of ink pixels of a row that's part of a text line:
+	minLineInk = 2
+	// minLineHeight and maxLineHeight are the limits in pixels of a text line height:
+	minLineHeight = 8
+	maxLineHeight = 80
+	// orientationMargin is the centroid offset, relative to the line height, needed to flip a page:
+	orientationMargin = 0.005
+	// minOrientationGaps is the amount of gaps needed to decide the orientation of a page:
+	minOrientationGaps = 24
+	// blankInk is the part of the profile maximum below which a position is blank:
+	blankInk = 0.05
+	// minGap is the amount of blank positions between two text lines:
+	minGap = 3
+	// maxStroke is the longest ink run in pixels considered text, longer runs are ruling lines:
+	maxStroke = 60
+	// thresholdWindow is the size of the window used by the adaptive threshold:
+	thresholdWindow = 31
+	// thresholdOffset is how much darker than its surroundings a pixel has to be to be ink:
+	thresholdOffset = 15
+)
+
+// Result describes the corrections applied to a page:
+type Result struct {
+	// Rotation is the clockwise rotation applied to fix the orientation: 0, 90, 180 or 270:
+	Rotation int `json:"rotation"`
+	// Skew is the detected skew angle in degrees, the image was rotated by its opposite:
+	Skew float64 `json:"skew"`
+}
+
+// File preprocesses a page image and writes the binarized result to output:
+func File(input, output string) (*Result, error) {
+	f, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	processed, result := Image(img)
+	out, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	if err := png.Encode(out, processed); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Image fixes the orientation and skew of a scanned page, removes speckles
+// and binarizes it with an adaptive threshold:
+func Image(img image.Image) (*image.Gray, *Result) {
+	gray := toGray(img)
+	result := &Result{}
+
+	if rotated90(gray) {
+		gray = rotate90(gray)
+		result.Rotation = 90
+	}
+	result.Skew = detectSkew(gray)
+	if result.Skew != 0 {
+		gray = rotate(gray, -result.Skew)
+	}
+	if upsideDown(gray) {
+		gray = rotate180(gray)
+		result.Rotation = (result.Rotation + 180) % 360
+	}
+	return threshold(despeckle(gray)), result
+}
+
+// toGray converts an image to grayscale:
+func toGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			gray.SetGray(x, y, color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray))
+		}
+	}
+	return gray
+}
+
+// inkPoints samples the positions of the dark pixels, relative to the image center:
+func inkPoints(gray *image.Gray) [][2]float64 {
+	bounds := gray.Bounds()
+	cx, cy := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	points := make([][2]float64, 0)
+	for y := 0; y < bounds.Dy(); y += sampleStep {
+		for x := 0; x < bounds.Dx(); x += sampleStep {
+			if gray.GrayAt(x, y).Y < inkThreshold {
+				points = append(points, [2]float64{float64(x) - cx, float64(y) - cy})
+			}
+		}
+	}
+	return points
+}
+
+// profileVariance returns the variance of the histogram of the given positions:
+func profileVariance(positions []float64, size int) float64 {
+	histogram := make([]float64, size+1)
+	offset := float64(size) / 2
+	for _, p := range positions {
+		i := int(p + offset)
+		if i >= 0 && i <= size {
+			histogram[i]++
+		}
+	}
+	var sum, squares float64
+	for _, v := range histogram {
+		sum += v
+		squares += v * v
+	}
+	n := float64(len(histogram))
+	mean := sum / n
+	return squares/n - mean*mean
+}
+
+// rotated90 reports if the text lines of the page are vertical. Blank rows separate text lines
+// while blank columns only separate columns of text, so a page that's the right way up has more
+// gaps in its row profile. Ruling lines would fill the gaps on forms, so only text ink is measured:
+func rotated90(gray *image.Gray) bool {
+	bounds := gray.Bounds()
+	text := textInk(gray)
+	rows, columns := make([]int, bounds.Dy()), make([]int, bounds.Dx())
+	for y := range text {
+		for x := range text[y] {
+			if text[y][x] {
+				rows[y]++
+				columns[x]++
+			}
+		}
+	}
+	rowGaps, columnGaps := gaps(rows), gaps(columns)
+	// Pages with little text are left as they are:
+	if rowGaps+columnGaps < minOrientationGaps {
+		return false
+	}
+	return float64(columnGaps) > float64(rowGaps)*orientationRatio
+}
+
+// gaps counts the runs of blank positions between the first and last ink of a profile,
+// positions with less than blankInk of the profile maximum are blank as remains of
+// ruling lines and noise are left:
+func gaps(profile []int) int {
+	peak := 0
+	for _, v := range profile {
+		peak = max(peak, v)
+	}
+	count, run := 0, 0
+	inside := false
+	for _, v := range profile {
+		if float64(v) > float64(peak)*blankInk {
+			if inside && run >= minGap {
+				count++
+			}
+			inside, run = true, 0
+			continue
+		}
+		run++
+	}
+	return count
+}
+
+// textInk returns the ink pixels that aren't part of horizontal or vertical runs
+// longer than maxStroke, which removes ruling lines and keeps the text:
+func textInk(gray *image.Gray) [][]bool {
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	text := make([][]bool, height)
+	for y := range text {
+		text[y] = make([]bool, width)
+		for x := range text[y] {
+			text[y][x] = gray.GrayAt(x, y).Y < inkThreshold
+		}
+	}
+	long := make([][]bool, height)
+	for y := range long {
+		long[y] = make([]bool, width)
+	}
+	for y := 0; y < height; y++ {
+		start := -1
+		for x := 0; x <= width; x++ {
+			ink := x < width && text[y][x]
+			if ink && start == -1 {
+				start = x
+			}
+			if !ink && start != -1 {
+				if x-start > maxStroke {
+					for i := start; i < x; i++ {
+						long[y][i] = true
+					}
+				}
+				start = -1
+			}
+		}
+	}
+	for x := 0; x < width; x++ {
+		start := -1
+		for y := 0; y <= height; y++ {
+			ink := y < height && text[y][x]
+			if ink && start == -1 {
+				start = y
+			}
+			if !ink && start != -1 {
+				if y-start > maxStroke {
+					for i := start; i < y; i++ {
+						long[i][x] = true
+					}
+				}
+				start = -1
+			}
+		}
+	}
+	for y := range text {
+		for x := range text[y] {
+			text[y][x] = text[y][x] && !long[y][x]
+		}
+	}
+	return text
+}
+
+// detectSkew returns the angle in degrees that makes the row profile sharpest,
+// text lines and ruling lines concentrate the ink in fewer rows when they're level:
+func detectSkew(gray *image.Gray) float64 {
+	points := inkPoints(gray)
+	if len(points) == 0 {
+		return 0
+	}
+	size := gray.Bounds().Dy() * 2
+	ys := make([]float64, len(points))
+	best, bestVariance := 0.0, -1.0
+	for angle := -maxSkew; angle <= maxSkew+skewStep/2; angle += skewStep {
+		rad := angle * math.Pi / 180
+		sin, cos := math.Sin(rad), math.Cos(rad)
+		for i, p := range points {
+			ys[i] = p[0]*sin + p[1]*cos
+		}
+		if variance := profileVariance(ys, size); variance > bestVariance {
+			best, bestVariance = angle, variance
+		}
+	}
+	return math.Round(-best/skewStep) * skewStep
+}
+
+// upsideDown reports if the page is rotated 180°. Capitals and ascenders put more ink
+// in the upper half of text lines, so the ink centroid of the lines moves down when
+// the page is upside down. Ruling lines and lines of unusual height are ignored:
+func upsideDown(gray *image.Gray) bool {
+	bounds := gray.Bounds()
+	profile := make([]int, bounds.Dy())
+	for y := range profile {
+		for x := 0; x < bounds.Dx(); x++ {
+			if gray.GrayAt(x, y).Y < inkThreshold {
+				profile[y]++
+			}
+		}
+	}
+	var offset, weight float64
+	start := -1
+	for y := 0; y <= len(profile); y++ {
+		blank := y == len(profile) || profile[y] < minLineInk
+		if !blank && start == -1 {
+			start = y
+		}
+		if !blank || start == -1 {
+			continue
+		}
+		height := y - start
+		if height >= minLineHeight && height <= maxLineHeight {
+			var mass, moment float64
+			ruled := false
+			for i := start; i < y; i++ {
+				mass += float64(profile[i])
+				moment += float64(profile[i]) * (float64(i-start) + 0.5) / float64(height)
+				ruled = ruled || profile[i] > bounds.Dx()/2
+			}
+			if !ruled {
+				offset += moment - mass/2
+				weight += mass
+			}
+		}
+		start = -1
+	}
+	return weight > 0 && offset/weight > orientationMargin
+}
+
+// rotate rotates the image by angle degrees -clockwise- around its center, uncovered areas are white:
+func rotate(gray *image.Gray, angle float64) *image.Gray {
+	bounds := gray.Bounds()
+	rotated := image.NewGray(bounds)
+	rad := angle * math.Pi / 180
+	sin, cos := math.Sin(rad), math.Cos(rad)
+	cx, cy := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			sx := int(math.Round(dx*cos + dy*sin + cx))
+			sy := int(math.Round(-dx*sin + dy*cos + cy))
+			value := uint8(255)
+			if sx >= 0 && sx < bounds.Dx() && sy >= 0 && sy < bounds.Dy() {
+				value = gray.GrayAt(sx, sy).Y
+			}
+			rotated.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return rotated
+}
+
+// rotate90 rotates the image 90° clockwise:
+func rotate90(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	rotated := image.NewGray(image.Rect(0, 0, bounds.Dy(), bounds.Dx()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rotated.SetGray(bounds.Dy()-1-y, x, gray.GrayAt(x, y))
+		}
+	}
+	return rotated
+}
+
+// rotate180 rotates the image 180°:
+func rotate180(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	rotated := image.NewGray(bounds)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rotated.SetGray(bounds.Dx()-1-x, bounds.Dy()-1-y, gray.GrayAt(x, y))
+		}
+	}
+	return rotated
+}
+
+// despeckle applies a 3x3 median filter, removing isolated dots left by the scanner:
+func despeckle(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	filtered := image.NewGray(bounds)
+	window := make([]uint8, 0, 9)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			window = window[:0]
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					sx, sy := min(max(x+dx, 0), bounds.Dx()-1), min(max(y+dy, 0), bounds.Dy()-1)
+					window = append(window, gray.GrayAt(sx, sy).Y)
+				}
+			}
+			sort.Slice(window, func(i, j int) bool { return window[i] < window[j] })
+			filtered.SetGray(x, y, color.Gray{Y: window[4]})
+		}
+	}
+	return filtered
+}
+
+// threshold binarizes the image comparing every pixel with the mean of its surroundings,
+// an integral image keeps the window mean constant time:
+func threshold(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	integral := make([]int64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		var row int64
+		for x := 0; x < width; x++ {
+			row += int64(gray.GrayAt(x, y).Y)
+			integral[(y+1)*(width+1)+x+1] = integral[y*(width+1)+x+1] + row
+		}
+	}
+	binary := image.NewGray(bounds)
+	half := thresholdWindow / 2
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			x0, y0 := max(x-half, 0), max(y-half, 0)
+			x1, y1 := min(x+half+1, width), min(y+half+1, height)
+			sum := integral[y1*(width+1)+x1] - integral[y0*(width+1)+x1] - integral[y1*(width+1)+x0] + integral[y0*(width+1)+x0]
+			mean := sum / int64((x1-x0)*(y1-y0))
+			value := uint8(255)
+			if int64(gray.GrayAt(x, y).Y) < mean-thresholdOffset {
+				value = 0
+			}
+			binary.SetGray(x, y, color.Gray{Y: value})
+		}
+	}
+	return binary
+}
@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/cluster"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+)
+
+// Cluster groups the unknown and unclassified documents by their layout
+// The clusters are kept in the store so they can be promoted later:
+func (p *Processor) Cluster(threshold float64) ([]*cluster.Cluster, error) {
+	if err := p.loadDocuments(); err != nil {
+		return nil, err
+	}
+	features := make(map[string]*cluster.Features)
+	for _, d := range p.store.RetrieveDocuments() {
+		if d.Type != "" && d.Type != types.UnknownDocumentType {
+			continue
+		}
+		f, err := cluster.ExtractFeatures(d)
+		if err != nil {
+			p.logger.Err(err).Msgf("error extracting features from %s", d.ID)
+			continue
+		}
+		features[d.ID] = f
+	}
+	p.logger.Info().Msgf("clustering %d documents", len(features))
+	clusters := cluster.Group(features, threshold)
+	if err := p.store.UpdateClusters(clusters); err != nil {
+		return nil, err
+	}
+	return clusters, nil
+}
+
+// PromoteCluster copies the representatives of a cluster into the samples path
+// and registers them as samples of the given label. The cluster members are marked
+// as unclassified so the next classification run compares them with the new samples:
+func (p *Processor) PromoteCluster(id int, label string) error {
+	if err := types.ValidateName(types.DocumentType(label)); err != nil {
+		return err
+	}
+	c := p.store.RetrieveCluster(id)
+	if c == nil {
+		return fmt.Errorf("cluster %d not found", id)
+	}
+	for _, documentID := range c.Representatives {
+		d := p.store.RetrieveDocument(documentID)
+		if d == nil {
+			return fmt.Errorf("document %s not found", documentID)
+		}
+		if err := p.addSample(label, d.PDFPath); err != nil {
+			return err
+		}
+	}
+	if err := p.cfg.SaveSampleData(); err != nil {
+		return err
+	}
+	for _, documentID := range c.Members {
+		if err := p.store.UpdateDocumentType(documentID, "", nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile copies a file, the destination is overwritten:
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
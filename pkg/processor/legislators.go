@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/legislator"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// ImportLegislators reads a roster CSV, see legislator.ReadCSV, and adds it to the registry
+// It returns the amount of new and updated persons:
+func (p *Processor) ImportLegislators(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	persons, err := legislator.ReadCSV(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	added, err := p.store.ImportLegislators(persons)
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, len(persons) - added, nil
+}
+
+// ImportMemberships reads a memberships CSV, see legislator.ReadMembershipsCSV, and adds them to the store
+// It returns the amount of new and updated memberships:
+func (p *Processor) ImportMemberships(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	memberships, err := legislator.ReadMembershipsCSV(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", path, err)
+	}
+	added, err := p.store.ImportMemberships(memberships)
+	if err != nil {
+		return 0, 0, err
+	}
+	return added, len(memberships) - added, nil
+}
+
+// ResolveLegislators matches the vote names of every extracted document to the registry, attaches
+// the party and bloc of each legislator and returns the documents that have unresolved names:
+func (p *Processor) ResolveLegislators() ([]*document.Document, error) {
+	if err := p.loadDocuments(); err != nil {
+		return nil, err
+	}
+	persons := p.store.RetrieveLegislators()
+	if len(persons) == 0 {
+		return nil, errors.New("the legislator registry is empty")
+	}
+	matcher := legislator.NewMatcher(persons)
+	memberships := p.store.RetrieveMemberships()
+	unresolved := make([]*document.Document, 0)
+	for _, d := range p.store.RetrieveDocuments() {
+		if d.Votes == nil {
+			continue
+		}
+		if err := p.resolveLegislators(d, matcher, memberships); err != nil {
+			return nil, err
+		}
+		if len(d.UnresolvedNames) > 0 {
+			unresolved = append(unresolved, d)
+		}
+	}
+	return unresolved, nil
+}
+
+// resolveLegislators sets the legislator ID of every vote of a document along with the party
+// and bloc at the vote date, names without a reliable match are flagged for review.
+// A nil matcher uses the stored registry and memberships, nothing is done while the registry is empty:
+func (p *Processor) resolveLegislators(d *document.Document, matcher *legislator.Matcher, memberships []*legislator.Membership) error {
+	if matcher == nil {
+		persons := p.store.RetrieveLegislators()
+		if len(persons) == 0 {
+			return nil
+		}
+		matcher = legislator.NewMatcher(persons)
+		memberships = p.store.RetrieveMemberships()
+	}
+	votes := make([]*vote.Vote, 0, len(d.Votes.Votes))
+	unresolved := make([]*document.UnresolvedName, 0)
+	for _, v := range d.Votes.Votes {
+		linked := *v
+		linked.LegislatorID, linked.Party, linked.Bloc = "", "", ""
+		votes = append(votes, &linked)
+		match, candidates := matcher.Resolve(v.Name)
+		if match != nil {
+			linked.LegislatorID = match.PersonID
+			if d.Votes.Date != "" {
+				linked.Party = legislator.Affiliation(memberships, match.PersonID, legislator.ClassificationParty, d.Votes.Date)
+				linked.Bloc = legislator.Affiliation(memberships, match.PersonID, legislator.ClassificationBloc, d.Votes.Date)
+			}
+			continue
+		}
+		name := &document.UnresolvedName{Name: v.Name}
+		for _, candidate := range candidates {
+			name.Candidates = append(name.Candidates, candidate.PersonID)
+		}
+		unresolved = append(unresolved, name)
+		p.logger.Debug().Msgf("%s: unresolved legislator %q, candidates: %v", d.ID, v.Name, name.Candidates)
+	}
+	if err := p.store.UpdateDocumentLegislators(d.ID, votes, unresolved); err != nil {
+		return err
+	}
+	_, err := p.writeVotes(d, d.Votes)
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +14,17 @@ import (
 	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/evaluation"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/extractor"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/layout"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/preprocess"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/prompts"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/rules"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/store"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/usage"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/validation"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -25,23 +35,47 @@ type Processor struct {
 	cfg *config.Config
 	// store is the main store:
 	store *store.Store
+	// types is the document type registry:
+	types *types.Registry
 	// logger is the main logger:
 	logger zerolog.Logger
 	// oaiClient is the OpenAI API client:
 	oaiClient *openai.OAIClient
 	// samples is a map of label -> sample documents:
 	samples map[string][]*document.Document
+	// usage tracks the token usage and cost of the current run:
+	usage *usage.Tracker
+	// prompts holds the prompt templates:
+	prompts *prompts.Prompts
+	// extractors maps document types to their extractor chain:
+	extractors *extractor.Registry
+	// validator checks the extracted votes:
+	validator *validation.Validator
 }
 
 // ClassificationOutput is the output of the classification step
-// The current prompt enforces the usage of this structure:
+// The JSON Schema sent to the model is derived from this structure:
 type ClassificationOutput struct {
 	// Label is the label of the sample that matched the document
-	// so it corresponds to the key in the sample data map:
-	Label string `json:"label"`
+	// so it corresponds to the key in the sample data map, it's not set by the model:
+	Label string `json:"label" jsonschema:"-"`
 	// Similar is a boolean that indicates if the document is similar
 	// For simplicity we don't currently ask for a confidence score:
-	Similar bool `json:"similar"`
+	Similar bool `json:"similar" jsonschema:"description=Whether both images share the same layout and format"`
+	// Info records the prompt and model that produced the classification:
+	Info *document.ResultInfo `json:"-"`
+}
+
+// completer binds completion calls to a document and stage so usage is attributed:
+type completer struct {
+	p          *Processor
+	documentID string
+	stage      string
+}
+
+// Completion implements openai.Completer:
+func (c *completer) Completion(req *openai.CompletionRequest) (*openai.CompletionResponse, error) {
+	return c.p.complete(c.documentID, c.stage, req)
 }
 
 // loadSamples loads the sample data from the configuration and
@@ -50,15 +84,17 @@ type ClassificationOutput struct {
 func (p *Processor) loadSamples() error {
 	p.logger.Info().Msg("Loading samples")
 	sampleCount := 0
-	for label, sampleDocs := range p.cfg.SampleData {
+	for _, definition := range p.types.Definitions() {
+		label := string(definition.Name)
 		if p.samples[label] == nil {
 			p.samples[label] = make([]*document.Document, 0)
 		}
-		for _, samplePath := range sampleDocs {
+		for _, samplePath := range definition.Samples {
 			p.logger.Debug().Msgf("Loading sample %s", samplePath)
 			fullPath := filepath.Join(p.cfg.SamplesPath, samplePath)
 			t := types.DocumentType(label)
 			doc := document.Document{
+				ID:        samplePath,
 				SourceURL: fullPath,
 				PDFPath:   fullPath,
 				Type:      t,
@@ -76,74 +112,152 @@ func (p *Processor) loadSamples() error {
 }
 
 // generateSampleImage generates a sample image for a given document
+// Renderings are cached using the PDF hash so they're only generated once:
 func (p *Processor) generateSampleImage(d *document.Document) error {
-	fileName := filepath.Base(d.SourceURL)
-	newFileName := strings.ReplaceAll(fileName, ".pdf", ".png")
-	newFilePath := filepath.Join(p.cfg.ImagePath, newFileName)
-	err := pdf2png.RenderPage(d.SourceURL, newFilePath, 0)
+	hash, err := fileHash(d.SourceURL)
 	if err != nil {
 		return err
 	}
+	newFilePath := filepath.Join(p.cfg.ImagePath, sampleImageDir, hash+".png")
+	if _, err := os.Stat(newFilePath); err == nil {
+		p.logger.Debug().Msgf("Using cached rendering %s", newFilePath)
+		d.ImagePaths = append(d.ImagePaths, newFilePath)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(newFilePath), 0755); err != nil {
+		return err
+	}
+	if err := pdf2png.RenderPage(d.SourceURL, newFilePath, 0); err != nil {
+		return err
+	}
 	d.ImagePaths = append(d.ImagePaths, newFilePath)
 	return nil
 }
 
+// newDocument initializes a document for a given PDF and renders its pages to imageDir:
+func (p *Processor) newDocument(path string, imageDir string) (*document.Document, error) {
+	fileName := filepath.Base(path)
+	newFileName := strings.ReplaceAll(fileName, ".pdf", ".png")
+	newFilePath := filepath.Join(imageDir, newFileName)
+	metadata, err := pdf2png.GetMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := document.Document{
+		ID:        fileName,
+		SourceURL: path,
+		PDFPath:   path,
+		Metadata:  metadata,
+	}
+	pageCount := metadata.PageCount
+
+	// Handle both single page and multi page scenarios:
+	if pageCount == 1 {
+		if err := pdf2png.RenderPage(path, newFilePath, 0); err != nil {
+			return nil, err
+		}
+		doc.ImagePaths = []string{newFilePath}
+	} else {
+		count := pageCount
+		imagePaths := make([]string, 0)
+
+		// When rendering multi page input use page count as a suffix in every rendered image:
+		for i := 0; i < count; i++ {
+			newFilePath := strings.ReplaceAll(newFilePath, ".png", fmt.Sprintf("_%d.png", i))
+			if err := pdf2png.RenderPage(path, newFilePath, i); err != nil {
+				return nil, err
+			}
+			imagePaths = append(imagePaths, newFilePath)
+		}
+		doc.ImagePaths = imagePaths
+	}
+	processed, err := p.preprocessPages(&doc)
+	if err != nil {
+		return nil, err
+	}
+	doc.ProcessedImages = processed
+	return &doc, nil
+}
+
+// preprocessPages writes a corrected and binarized copy of every scanned page
+// when preprocessing is enabled, pages with a text layer are skipped
+// nil is returned when preprocessing is disabled:
+func (p *Processor) preprocessPages(d *document.Document) ([]*document.ProcessedImage, error) {
+	if !p.cfg.ExtractionConfig.Preprocess || d.Metadata == nil {
+		return nil, nil
+	}
+	processed := make([]*document.ProcessedImage, 0)
+	for i, imagePath := range d.ImagePaths {
+		if i < len(d.Metadata.Pages) && d.Metadata.Pages[i].HasText {
+			continue
+		}
+		outputPath := strings.TrimSuffix(imagePath, ".png") + "_processed.png"
+		result, err := preprocess.File(imagePath, outputPath)
+		if err != nil {
+			return nil, err
+		}
+		p.logger.Debug().Msgf("preprocessed %s: rotation %d, skew %.1f", imagePath, result.Rotation, result.Skew)
+		processed = append(processed, &document.ProcessedImage{
+			Page:      i,
+			ImagePath: outputPath,
+			Rotation:  result.Rotation,
+			Skew:      result.Skew,
+		})
+	}
+	return processed, nil
+}
+
 // loadDocuments loads the documents from the PDFs path:
 func (p *Processor) loadDocuments() error {
 	p.logger.Info().Msg("Loading documents")
-	filepath.WalkDir(p.cfg.PDFPath, func(path string, d os.DirEntry, err error) error {
+	err := filepath.WalkDir(p.cfg.PDFPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		// Skip all non-PDF files:
 		if filepath.Ext(path) != ".pdf" {
 			return nil
 		}
 		fileName := filepath.Base(path)
 
-		// First check if document exists or not:
+		// First check if document exists or not
+		// Documents ingested before metadata or preprocessing was added get them now:
 		if doc := p.store.RetrieveDocument(fileName); doc != nil {
 			p.logger.Debug().Msgf("Document %s already exists - skipping", fileName)
-			return nil
-		}
-
-		newFileName := strings.ReplaceAll(fileName, ".pdf", ".png")
-		newFilePath := filepath.Join(p.cfg.ImagePath, newFileName)
-		doc := document.Document{
-			ID:        fileName,
-			SourceURL: path,
-			PDFPath:   path,
+			if doc.Metadata == nil {
+				metadata, err := pdf2png.GetMetadata(path)
+				if err != nil {
+					return err
+				}
+				if err := p.store.UpdateDocumentMetadata(doc.ID, metadata); err != nil {
+					return err
+				}
+			}
+			if doc.ProcessedImages != nil {
+				return nil
+			}
+			// An empty list is stored for documents without scanned pages, so they aren't checked again:
+			processed, err := p.preprocessPages(doc)
+			if err != nil || processed == nil {
+				return err
+			}
+			return p.store.UpdateDocumentProcessedImages(doc.ID, processed)
 		}
 
-		pageCount, err := pdf2png.GetPageCount(path)
+		doc, err := p.newDocument(path, p.cfg.ImagePath)
 		if err != nil {
 			return err
 		}
 
-		// Handle both single page and multi page scenarios:
-		if pageCount == 1 {
-			if err := pdf2png.RenderPage(path, newFilePath, 0); err != nil {
-				return err
-			}
-			doc.ImagePaths = []string{newFilePath}
-		} else {
-			count := pageCount
-			imagePaths := make([]string, 0)
-
-			// When rendering multi page input use page count as a suffix in every rendered image:
-			for i := 0; i < count; i++ {
-				newFilePath := strings.ReplaceAll(newFilePath, ".png", fmt.Sprintf("_%d.png", i))
-				if err := pdf2png.RenderPage(path, newFilePath, i); err != nil {
-					return err
-				}
-				imagePaths = append(imagePaths, newFilePath)
-			}
-			doc.ImagePaths = imagePaths
-		}
-
 		// Store the updated document data:
-		if err := p.store.AppendDocument(doc.ID, &doc); err != nil {
+		if err := p.store.AppendDocument(doc.ID, doc); err != nil {
 			return err
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 	p.logger.Info().Msgf("Loaded %d documents", p.store.GetDocumentCount())
 	return nil
 }
@@ -152,28 +266,43 @@ func (p *Processor) loadDocuments() error {
 // It's currently very inefficient due to API rate limiting
 // Using batch requests should improve it:
 func (p *Processor) classifyDocument(d *document.Document) (*ClassificationOutput, error) {
+	// Deterministic rules are checked first to avoid model calls:
+	classification, err := p.classifyByRules(d)
+	if err != nil {
+		return nil, err
+	}
+	if classification != nil {
+		return classification, nil
+	}
+
 	docImage, err := d.ImageAsBase64()
 	if err != nil {
 		return nil, err
 	}
 	for label, samples := range p.samples {
+		// Types without samples can only be classified by rules:
+		if len(samples) == 0 {
+			continue
+		}
 		sample := samples[0]
 		p.logger.Debug().Msgf("Comparing '%s' with sample '%s'", filepath.Base(d.ID), label)
 		sampleImageB64, _ := sample.ImageAsBase64()
+		definition := p.types.Get(types.DocumentType(label))
+		prompt, err := p.prompts.Render(prompts.Classification, map[string]string{
+			"Label":       label,
+			"Description": definition.Description,
+			"Hints":       definition.Hints,
+		})
+		if err != nil {
+			return nil, err
+		}
 		completionRequest := openai.CompletionRequest{
 			MaxTokens: 3000,
 			Messages: []openai.Message{
 				{Role: "user", Content: []openai.ContentItem{
 					{
 						Type: "text",
-						Text: `
-Analyze the layout and format of the two images.
-If the images are highly similar, return a JSON object with the following structure:
-{"simiar": true}
-If the images are not similar return:
-{"similar": false}
-Don't return any more output than JSON.
-`,
+						Text: prompt.Text,
 					},
 					{
 						Type: "image_url",
@@ -190,16 +319,13 @@ Don't return any more output than JSON.
 				}},
 			},
 		}
-		res, err := p.oaiClient.Completion(&completionRequest)
-		if err != nil {
-			return nil, err
-		}
-		if len(res.Choices) == 0 {
-			return nil, errors.New("no choices returned from completion API")
-		}
-
-		// Take the first/only choice and parse the output:
-		classification, err := p.getClassification(res.Choices[0])
+		var classification ClassificationOutput
+		_, err = openai.StructuredCompletion(
+			&completer{p: p, documentID: d.ID, stage: usage.StageClassification},
+			&completionRequest,
+			&classification,
+			p.structuredOptions("classification"),
+		)
 		if err != nil {
 			log.Err(err).Msg("error getting classification")
 			return nil, err
@@ -208,37 +334,102 @@ Don't return any more output than JSON.
 		// If similar return earlier and avoid further processing against other samples:
 		if classification.Similar {
 			classification.Label = label
-			return classification, nil
+			classification.Info = &document.ResultInfo{
+				Method:        "llm",
+				Model:         completionRequest.Model,
+				Prompt:        prompt.Name,
+				PromptVersion: prompt.Version,
+				CreatedAt:     time.Now(),
+			}
+			return &classification, nil
 		}
 	}
 
 	return nil, nil
 }
 
-// getClassification parses the classification output from the OpenAI API:
-func (p *Processor) getClassification(choice openai.CompletionResponseChoice) (*ClassificationOutput, error) {
-	jsonBlock := choice.Message.Content
-	if len(jsonBlock) == 0 {
-		return nil, errors.New("no content found")
+// classifyByRules classifies a document using the rules of each document type
+// nil is returned when no type or more than one type matches:
+func (p *Processor) classifyByRules(d *document.Document) (*ClassificationOutput, error) {
+	// The text layer isn't persisted, so stored metadata is only reused within the same run:
+	metadata := d.Metadata
+	if metadata == nil || metadata.Texts == nil {
+		var err error
+		metadata, err = pdf2png.GetMetadata(d.PDFPath)
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	// Do some basic checks to make sure we're parsing the right thing:
-	if strings.Contains(jsonBlock, "```json") {
-		splits := strings.Split(jsonBlock, "```json")
-		if len(splits) == 0 {
-			return nil, errors.New("no JSON code block found")
+	facts := rules.FactsFromMetadata(metadata)
+	matches := make([]types.DocumentType, 0)
+	for _, definition := range p.types.Definitions() {
+		if rules.Match(definition.Rules, facts) {
+			matches = append(matches, definition.Name)
+		}
+	}
+	if len(matches) != 1 {
+		if len(matches) > 1 {
+			p.logger.Warn().Msgf("rules for %v match %s, falling back to the model", matches, d.ID)
 		}
-		split := splits[1]
-		jsonBlock = strings.Split(split, "```")[0]
+		return nil, nil
 	}
+	p.logger.Debug().Msgf("%s matched the rules for %s", d.ID, matches[0])
+	return &ClassificationOutput{
+		Label:   string(matches[0]),
+		Similar: true,
+		Info: &document.ResultInfo{
+			Method:    "rules",
+			CreatedAt: time.Now(),
+		},
+	}, nil
+}
 
-	// Actually unmarshal the JSON block:
-	var output ClassificationOutput
-	reader := strings.NewReader(jsonBlock)
-	if err := json.NewDecoder(reader).Decode(&output); err != nil {
+// complete calls the completion API and records the token usage
+// for the given document and stage. Calls are refused once the spend
+// cap is reached, so the response of the call that crosses it is kept:
+func (p *Processor) complete(documentID, stage string, req *openai.CompletionRequest) (*openai.CompletionResponse, error) {
+	model := req.Model
+	if model == "" {
+		model = openai.DefaultModel
+	}
+	if err := p.usage.CheckPricing(model); err != nil {
+		return nil, err
+	}
+	if p.usage.Exceeded() {
+		return nil, usage.ErrBudgetExceeded
+	}
+	res, err := p.oaiClient.Completion(req)
+	if err != nil {
 		return nil, err
 	}
-	return &output, nil
+	record := p.usage.Add(documentID, stage, req.Model, res.Usage, res.Cached)
+	if err := p.store.AppendUsage(record); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// UsageSummary returns the token usage and cost of the current run:
+func (p *Processor) UsageSummary() *usage.Summary {
+	return p.usage.Summary()
+}
+
+// logUsageSummary logs the usage of the current run, per stage:
+func (p *Processor) logUsageSummary() {
+	summary := p.usage.Summary()
+	for stage, totals := range summary.ByStage {
+		p.logger.Info().Msgf("usage for %s: %s", stage, totals)
+	}
+	p.logger.Info().Msgf("usage for run %s: %s", p.usage.RunID(), &summary.Totals)
+}
+
+// structuredOptions returns the structured completion options for a given output name:
+func (p *Processor) structuredOptions(name string) openai.StructuredOptions {
+	return openai.StructuredOptions{
+		Name:           name,
+		ResponseFormat: p.cfg.OpenAIConfig.ResponseFormat,
+		MaxAttempts:    p.cfg.OpenAIConfig.MaxAttempts,
+	}
 }
 
 // Classify is the high level classification step:
@@ -255,6 +446,7 @@ func (p *Processor) Classify() error {
 
 	// Loop forever?
 	for {
+		classified := 0
 		for _, d := range p.store.RetrieveDocuments() {
 			if d.Type != "" {
 				p.logger.Info().Msgf("skipping %s - already classified", d.ID)
@@ -264,6 +456,11 @@ func (p *Processor) Classify() error {
 			baseName := filepath.Base(d.PDFPath)
 			p.logger.Info().Msgf("classifying %s", baseName)
 			classification, err := p.classifyDocument(d)
+			if errors.Is(err, usage.ErrBudgetExceeded) {
+				p.logger.Warn().Msgf("spend cap of $%.2f reached, stopping", p.cfg.OpenAIConfig.MaxCost)
+				p.logUsageSummary()
+				return nil
+			}
 			if err != nil {
 				p.logger.Err(err).Msg("error classifying document")
 				continue
@@ -271,32 +468,355 @@ func (p *Processor) Classify() error {
 			diff := time.Since(ts)
 			p.logger.Info().Msgf("done: %+v - took %d seconds", classification, diff.Milliseconds())
 
+			// No sample matched, flag it so it's not retried on every pass:
+			if classification == nil {
+				promptVersion, _ := p.prompts.Version(prompts.Classification)
+				classification = &ClassificationOutput{
+					Label: string(types.UnknownDocumentType),
+					Info: &document.ResultInfo{
+						Method:        "llm",
+						Prompt:        prompts.Classification,
+						PromptVersion: promptVersion,
+						CreatedAt:     time.Now(),
+					},
+				}
+			}
+
 			// Update store:
-			if err := p.store.UpdateDocumentType(d.ID, classification.Label); err != nil {
+			if err := p.store.UpdateDocumentType(d.ID, classification.Label, classification.Info); err != nil {
 				return err
 			}
+			classified++
+		}
+		if classified > 0 {
+			p.logUsageSummary()
 		}
 		time.Sleep(5 * time.Second)
 	}
 }
 
-// Extract is the high level extraction step:
-func (p *Processor) Extract() error {
+// extractionInput builds the extractor input with the rendered pages and the text layer:
+func (p *Processor) extractionInput(d *document.Document) (*extractor.Input, error) {
+	texts, err := pdf2png.GetPageTexts(d.PDFPath)
+	if err != nil {
+		return nil, err
+	}
+	in := &extractor.Input{
+		Document:  d,
+		Pages:     make([]*extractor.Page, 0, len(d.ImagePaths)),
+		Completer: &completer{p: p, documentID: d.ID, stage: usage.StageExtraction},
+	}
+	for i, imagePath := range d.ImagePaths {
+		page := &extractor.Page{Index: i, ImagePath: imagePath, ProcessedImagePath: d.ProcessedImagePath(i)}
+		if i < len(texts) {
+			page.Text = texts[i]
+		}
+		in.Pages = append(in.Pages, page)
+	}
+	return in, nil
+}
+
+// extractDocument extracts the vote record of a classified document
+// using the extractor chain set for its type:
+func (p *Processor) extractDocument(d *document.Document) (*document.ExtractionResult, error) {
+	in, err := p.extractionInput(d)
+	if err != nil {
+		return nil, err
+	}
+	return p.extractors.Extract(in)
+}
+
+// saveVotes writes the extracted votes to the JSON path, updates the store
+// and resolves the vote names to the legislator registry:
+func (p *Processor) saveVotes(d *document.Document, result *document.ExtractionResult) error {
+	jsonPath, err := p.writeVotes(d, result.Votes)
+	if err != nil {
+		return err
+	}
+	if err := p.store.UpdateDocumentVotes(d.ID, jsonPath, result); err != nil {
+		return err
+	}
+	return p.resolveLegislators(d, nil, nil)
+}
+
+// writeVotes writes a vote record to the JSON path and returns the file path:
+func (p *Processor) writeVotes(d *document.Document, record *vote.Record) (string, error) {
+	rawRecord, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	jsonPath := filepath.Join(p.cfg.JSONPath, strings.TrimSuffix(d.ID, filepath.Ext(d.ID))+".json")
+	if err := os.WriteFile(jsonPath, rawRecord, 0644); err != nil {
+		return "", err
+	}
+	return jsonPath, nil
+}
+
+// Evaluate runs the pipeline over a directory of labeled PDFs, see evaluation.LoadGroundTruth
+// Rendered images are written to a temporary directory and the documents aren't added to the store:
+func (p *Processor) Evaluate(dir string) (*evaluation.Report, error) {
+	cases, err := evaluation.LoadGroundTruth(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, errors.New("no labeled documents found")
+	}
+	if err := p.loadSamples(); err != nil {
+		return nil, err
+	}
+	imageDir, err := os.MkdirTemp("", "evaluacion")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(imageDir)
+	for i, c := range cases {
+		p.logger.Info().Msgf("evaluating %s", c.ID)
+		// Every case gets its own directory as the image names derived from different PDFs may collide,
+		// e.g. the first page of "acta.pdf" and the single page of "acta_0.pdf" are both "acta_0.png":
+		caseDir := filepath.Join(imageDir, strconv.Itoa(i))
+		if err := os.MkdirAll(caseDir, 0755); err != nil {
+			return nil, err
+		}
+		d, err := p.newDocument(c.PDFPath, caseDir)
+		if err != nil {
+			c.Error = err.Error()
+			continue
+		}
+		classification, err := p.classifyDocument(d)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			c.Error = err.Error()
+			continue
+		}
+		d.Type = types.UnknownDocumentType
+		if classification != nil {
+			d.Type = types.DocumentType(classification.Label)
+		}
+		c.PredictedType = string(d.Type)
+		if c.Expected.Votes == nil {
+			continue
+		}
+		result, err := p.extractDocument(d)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			return nil, err
+		}
+		if err != nil {
+			c.Error = err.Error()
+			continue
+		}
+		c.Extracted = result.Votes
+	}
+	return evaluation.Evaluate(cases, p.usage.Summary()), nil
+}
+
+// extractEnsemble runs every extractor of the document type and compares their results
+// The votes of the first successful extractor are stored, disagreements are logged for review:
+func (p *Processor) extractEnsemble(d *document.Document) error {
+	in, err := p.extractionInput(d)
+	if err != nil {
+		return err
+	}
+	results, comparison, err := p.extractors.Ensemble(in)
+	if err != nil {
+		return err
+	}
+	if err := p.saveVotes(d, results[0]); err != nil {
+		return err
+	}
+	if err := p.store.UpdateDocumentComparison(d.ID, results, comparison); err != nil {
+		return err
+	}
+	for name, failure := range comparison.Failures {
+		p.logger.Warn().Msgf("%s: %s failed: %s", d.ID, name, failure)
+	}
+	for _, disagreement := range comparison.Disagreements {
+		p.logger.Warn().Msgf("%s: disagreement on %s %s: %v", d.ID, disagreement.Field, disagreement.Name, disagreement.Values)
+	}
+	if comparison.Accepted {
+		p.logger.Info().Msgf("done: %d votes, %s agree", len(results[0].Votes.Votes), strings.Join(comparison.Extractors, ", "))
+	} else {
+		p.logger.Info().Msgf("done: %d votes using %s, flagged for review", len(results[0].Votes.Votes), results[0].Info.Extractor)
+	}
+	return nil
+}
+
+// Extract is the high level extraction step
+// When ensemble is set every extractor of the document type is run and their results are compared,
+// documents extracted without the ensemble mode are extracted again
+// Documents with votes corrected by a reviewer are only extracted again when force is set:
+func (p *Processor) Extract(ensemble, force bool) error {
 	// Load documents into memory:
 	if err := p.loadDocuments(); err != nil {
 		return err
 	}
+
+	for _, d := range p.store.RetrieveDocuments() {
+		if d.Type == "" || d.Type == types.UnknownDocumentType {
+			p.logger.Debug().Msgf("skipping %s - not classified", d.ID)
+			continue
+		}
+		if d.Votes != nil && len(d.Reviews) > 0 && !force {
+			p.logger.Info().Msgf("skipping %s - reviewed", d.ID)
+			continue
+		}
+		if d.Votes != nil && !force && (!ensemble || d.Comparison != nil) {
+			p.logger.Info().Msgf("skipping %s - already extracted", d.ID)
+			continue
+		}
+		if ensemble && d.Votes != nil && !force && len(p.extractors.Chain(d.Type)) < 2 {
+			p.logger.Info().Msgf("skipping %s - type %s has a single extractor", d.ID, d.Type)
+			continue
+		}
+		ts := time.Now()
+		p.logger.Info().Msgf("extracting %s - type %s", d.ID, d.Type)
+		if ensemble && len(p.extractors.Chain(d.Type)) > 1 {
+			err := p.extractEnsemble(d)
+			if errors.Is(err, usage.ErrBudgetExceeded) {
+				p.logger.Warn().Msgf("spend cap of $%.2f reached, stopping", p.cfg.OpenAIConfig.MaxCost)
+				return nil
+			}
+			if err != nil {
+				p.logger.Err(err).Msgf("error extracting %s", d.ID)
+				continue
+			}
+			if _, err := p.validateDocument(d); err != nil {
+				return err
+			}
+			continue
+		}
+		result, err := p.extractDocument(d)
+		if errors.Is(err, usage.ErrBudgetExceeded) {
+			p.logger.Warn().Msgf("spend cap of $%.2f reached, stopping", p.cfg.OpenAIConfig.MaxCost)
+			return nil
+		}
+		if err != nil {
+			p.logger.Err(err).Msgf("error extracting %s", d.ID)
+			continue
+		}
+		p.logger.Info().Msgf("done: %d votes using %s - took %d ms", len(result.Votes.Votes), result.Info.Extractor, time.Since(ts).Milliseconds())
+		if err := p.saveVotes(d, result); err != nil {
+			return err
+		}
+		if _, err := p.validateDocument(d); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// validateDocument checks the extracted votes of a document and stores the result
+// A previous override is kept so accepted documents stay exportable:
+func (p *Processor) validateDocument(d *document.Document) (*document.Validation, error) {
+	var chamber string
+	if definition := p.types.Get(d.Type); definition != nil {
+		chamber = definition.Chamber
+	}
+	result := &document.Validation{
+		Errors:    p.validator.Validate(d.Votes, chamber),
+		CheckedAt: time.Now(),
+	}
+	if d.Validation != nil {
+		result.Override = d.Validation.Override
+	}
+	for _, validationErr := range result.Errors {
+		p.logger.Warn().Msgf("%s failed %s check: %s", d.ID, validationErr.Check, validationErr.Message)
+	}
+	if err := p.store.UpdateDocumentValidation(d.ID, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Validate checks the extracted votes of every document, or a single one when documentID is set
+// When override is set the document is accepted for export even if it fails, this requires a documentID:
+func (p *Processor) Validate(documentID string, override *document.ValidationOverride) ([]*document.Document, error) {
+	if err := p.loadDocuments(); err != nil {
+		return nil, err
+	}
+	if override != nil && documentID == "" {
+		return nil, errors.New("an override requires a document")
+	}
+	docs := p.store.RetrieveDocuments()
+	if documentID != "" {
+		d := p.store.RetrieveDocument(documentID)
+		if d == nil {
+			return nil, fmt.Errorf("document %s not found", documentID)
+		}
+		docs = []*document.Document{d}
+	}
+	validated := make([]*document.Document, 0, len(docs))
+	for _, d := range docs {
+		if d.Votes == nil {
+			if documentID != "" {
+				return nil, fmt.Errorf("%s has no extracted votes", d.ID)
+			}
+			continue
+		}
+		if _, err := p.validateDocument(d); err != nil {
+			return nil, err
+		}
+		if override != nil {
+			d.Validation.Override = override
+			if err := p.store.UpdateDocumentValidation(d.ID, d.Validation); err != nil {
+				return nil, err
+			}
+		}
+		validated = append(validated, d)
+	}
+	return validated, nil
+}
+
 // New initializes a new processor with the given components:
-func New(cfg *config.Config, store *store.Store, logger zerolog.Logger) *Processor {
+func New(cfg *config.Config, store *store.Store, registry *types.Registry, logger zerolog.Logger) *Processor {
 	p := &Processor{
+		types:     registry,
 		samples:   make(map[string][]*document.Document),
 		oaiClient: openai.New(cfg),
+		usage:     usage.NewTracker(cfg),
+		prompts:   prompts.New(cfg),
 		cfg:       cfg,
 		store:     store,
 		logger:    logger,
 	}
 	return p
 }
+
+// Init loads the layout templates, builds the extractor chains of every document type
+// and the vote validator:
+func (p *Processor) Init() error {
+	templates, err := layout.Load(p.cfg.TemplatesPath)
+	if err != nil {
+		return err
+	}
+	for name := range templates {
+		if p.types.Get(types.DocumentType(name)) == nil {
+			return fmt.Errorf("template for unknown document type: %s", name)
+		}
+	}
+	extractors, err := extractor.NewRegistry(p.types,
+		extractor.NewScannedTable(p.cfg, p.prompts),
+		extractor.NewMinutes(),
+		extractor.NewPositional(p.cfg, templates),
+		extractor.NewVoteTable(),
+	)
+	if err != nil {
+		return err
+	}
+	p.extractors = extractors
+	validator, err := validation.New(p.cfg)
+	if err != nil {
+		return err
+	}
+	p.validator = validator
+	// The spend cap relies on the model price:
+	if err := p.usage.CheckPricing(openai.DefaultModel); err != nil {
+		return err
+	}
+	if !p.usage.Priced(openai.DefaultModel) {
+		p.logger.Warn().Msgf("no pricing for model %s, the usage cost will be reported as zero", openai.DefaultModel)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// Correct applies a reviewer correction to a document: the type and the votes -when set- replace the
+// current ones, the changes are recorded along with the reviewer and the votes are validated again
+// A correction without changes records that the reviewer accepted the document as is:
+func (p *Processor) Correct(documentID, reviewer string, docType types.DocumentType, record *vote.Record) (*document.Review, error) {
+	d := p.store.RetrieveDocument(documentID)
+	if d == nil {
+		return nil, fmt.Errorf("document %s not found", documentID)
+	}
+	if reviewer == "" {
+		return nil, errors.New("a review requires a reviewer")
+	}
+	review := &document.Review{
+		By:      reviewer,
+		At:      time.Now(),
+		Changes: make([]*document.Change, 0),
+	}
+	if docType != d.Type {
+		review.Changes = append(review.Changes, &document.Change{Field: "type", Old: string(d.Type), New: string(docType)})
+	}
+	var jsonPath string
+	if record != nil {
+		review.Changes = append(review.Changes, diffRecords(d.Votes, record)...)
+		path, err := p.writeVotes(d, record)
+		if err != nil {
+			return nil, err
+		}
+		jsonPath = path
+	}
+	if err := p.store.AddDocumentReview(d.ID, docType, record, jsonPath, review); err != nil {
+		return nil, err
+	}
+	p.logger.Info().Msgf("%s reviewed by %s: %d changes", d.ID, reviewer, len(review.Changes))
+	if d.Votes != nil {
+		if err := p.resolveLegislators(d, nil, nil); err != nil {
+			return nil, err
+		}
+		if _, err := p.validateDocument(d); err != nil {
+			return nil, err
+		}
+	}
+	return review, nil
+}
+
+// diffRecords returns the changes between two vote records, old may be nil:
+func diffRecords(old, updated *vote.Record) []*document.Change {
+	if old == nil {
+		old = &vote.Record{}
+	}
+	changes := make([]*document.Change, 0)
+	oldFields := old.Fields()
+	for i, field := range updated.Fields() {
+		if previous := oldFields[i].Value; previous != field.Value {
+			changes = append(changes, &document.Change{Field: field.Name, Old: previous, New: field.Value})
+		}
+	}
+	for _, option := range vote.TotalOptions(old, updated) {
+		oldTotal, newTotal := old.TotalString(option), updated.TotalString(option)
+		if oldTotal != newTotal {
+			changes = append(changes, &document.Change{Field: "totals." + string(option), Old: oldTotal, New: newTotal})
+		}
+	}
+	for _, v := range updated.Votes {
+		previous := old.VoteByName(v.Name)
+		if previous == nil {
+			changes = append(changes, &document.Change{Field: "vote", Name: v.Name, New: string(v.Option)})
+			continue
+		}
+		if previous.Option != v.Option || previous.Name != v.Name {
+			changes = append(changes, &document.Change{Field: "vote", Name: v.Name, Old: string(previous.Option), New: string(v.Option)})
+		}
+	}
+	for _, v := range old.Votes {
+		if updated.VoteByName(v.Name) == nil {
+			changes = append(changes, &document.Change{Field: "vote", Name: v.Name, Old: string(v.Option)})
+		}
+	}
+	return changes
+}
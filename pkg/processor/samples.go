@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/cluster"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+)
+
+const (
+	// sampleImageDir is the directory inside the image path used for sample renderings:
+	sampleImageDir = "samples"
+)
+
+// AddSample copies a stored document into the samples path and registers it as a sample of the given label:
+func (p *Processor) AddSample(label, documentID string) error {
+	if err := types.ValidateName(types.DocumentType(label)); err != nil {
+		return err
+	}
+	d := p.store.RetrieveDocument(documentID)
+	if d == nil {
+		return fmt.Errorf("document %s not found", documentID)
+	}
+	if err := p.addSample(label, d.PDFPath); err != nil {
+		return err
+	}
+	return p.cfg.SaveSampleData()
+}
+
+// addSample copies a PDF into the samples path and adds it to the sample data,
+// the config file isn't saved:
+func (p *Processor) addSample(label, pdfPath string) error {
+	if p.cfg.SampleData == nil {
+		p.cfg.SampleData = make(map[string][]string)
+	}
+	sampleName := label + "_" + filepath.Base(pdfPath)
+	if err := copyFile(pdfPath, filepath.Join(p.cfg.SamplesPath, sampleName)); err != nil {
+		return err
+	}
+	if !contains(p.cfg.SampleData[label], sampleName) {
+		p.cfg.SampleData[label] = append(p.cfg.SampleData[label], sampleName)
+	}
+	p.logger.Info().Msgf("added %s as a sample for %s", sampleName, label)
+	return nil
+}
+
+// RemoveSample removes a sample from the sample data, the file is kept in the samples path
+// Samples defined in the "document_types" block must be removed by editing the config:
+func (p *Processor) RemoveSample(label, sampleName string) error {
+	samples := p.cfg.SampleData[label]
+	if !contains(samples, sampleName) {
+		if definition := p.types.Get(types.DocumentType(label)); definition != nil && contains(definition.Samples, sampleName) {
+			return fmt.Errorf("sample %s is defined in document_types, edit the config file to remove it", sampleName)
+		}
+		return fmt.Errorf("sample %s not found for %s", sampleName, label)
+	}
+	remaining := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		if sample != sampleName {
+			remaining = append(remaining, sample)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(p.cfg.SampleData, label)
+	} else {
+		p.cfg.SampleData[label] = remaining
+	}
+	return p.cfg.SaveSampleData()
+}
+
+// ValidateSamples checks that every label has samples, that they can be rendered and that samples
+// of different labels are distinguishable. A list of problems is returned:
+func (p *Processor) ValidateSamples() ([]string, error) {
+	problems := make([]string, 0)
+	for _, definition := range p.types.Definitions() {
+		if len(definition.Samples) == 0 {
+			problems = append(problems, fmt.Sprintf("%s has no samples", definition.Name))
+		}
+	}
+	if err := p.loadSamples(); err != nil {
+		problems = append(problems, fmt.Sprintf("rendering error: %s", err))
+		return problems, nil
+	}
+
+	// Compare every sample with the samples of the other labels:
+	features := make(map[string]*cluster.Features)
+	labels := make(map[string]string)
+	for label, samples := range p.samples {
+		for _, sample := range samples {
+			f, err := cluster.ExtractFeatures(sample)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", sample.ID, err))
+				continue
+			}
+			features[sample.ID] = f
+			labels[sample.ID] = label
+		}
+	}
+	for a, featuresA := range features {
+		for b, featuresB := range features {
+			if a >= b || labels[a] == labels[b] {
+				continue
+			}
+			if distance := cluster.Distance(featuresA, featuresB); distance < cluster.DefaultThreshold {
+				problems = append(problems, fmt.Sprintf(
+					"%s (%s) and %s (%s) are too similar: %.3f",
+					a, labels[a], b, labels[b], distance,
+				))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// fileHash returns the SHA-256 hash of a file:
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,110 @@
+package prompts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+)
+
+// Prompt names:
+const (
+	Classification = "classification"
+	ScannedTable   = "scanned_table"
+)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+var (
+	errPromptNotFound = errors.New("prompt not found")
+
+	// versionRe matches the version comment at the top of every template:
+	versionRe = regexp.MustCompile(`^\{\{/\*\s*version:\s*(\S+)\s*\*/`)
+)
+
+// Prompts loads prompt templates, by default the embedded ones are used
+// but a template with the same name in the prompts path takes precedence:
+type Prompts struct {
+	cfg *config.Config
+}
+
+// Rendered is a rendered prompt, along with the template version that produced it:
+type Rendered struct {
+	Name    string
+	Version string
+	Text    string
+}
+
+// source returns the raw template, checking the prompts path first:
+func (p *Prompts) source(name string) ([]byte, error) {
+	fileName := name + ".tmpl"
+	if p.cfg.PromptsPath != "" {
+		raw, err := os.ReadFile(filepath.Join(p.cfg.PromptsPath, fileName))
+		if err == nil {
+			return raw, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	raw, err := embedded.ReadFile("templates/" + fileName)
+	if err != nil {
+		return nil, errPromptNotFound
+	}
+	return raw, nil
+}
+
+// Version returns the version of a prompt, templates without a version
+// comment get one derived from their contents:
+func (p *Prompts) Version(name string) (string, error) {
+	raw, err := p.source(name)
+	if err != nil {
+		return "", err
+	}
+	return version(raw), nil
+}
+
+func version(raw []byte) string {
+	if match := versionRe.FindSubmatch(raw); match != nil {
+		return string(match[1])
+	}
+	sum := sha256.Sum256(raw)
+	return "sha256-" + hex.EncodeToString(sum[:])[:12]
+}
+
+// Render renders a prompt with the given data:
+func (p *Prompts) Render(name string, data any) (*Rendered, error) {
+	raw, err := p.source(name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(raw))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return &Rendered{
+		Name:    name,
+		Version: version(raw),
+		Text:    strings.TrimSpace(buf.String()),
+	}, nil
+}
+
+// New initializes the prompts with the given config:
+func New(cfg *config.Config) *Prompts {
+	return &Prompts{
+		cfg: cfg,
+	}
+}
@@ -0,0 +1,397 @@
+package review
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/processor"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/store"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+	"github.com/rs/zerolog"
+)
+
+// defaultMinConfidence is used when the review configuration doesn't set a minimum confidence:
+const defaultMinConfidence = 0.8
+
+// blankRows is the amount of empty vote rows shown to add missing legislators:
+const blankRows = 3
+
+//go:embed templates/*.html
+var embedded embed.FS
+
+// tokenSize is the size in bytes of the form token:
+const tokenSize = 32
+
+var (
+	errBadForm   = errors.New("invalid form")
+	errForbidden = errors.New("invalid form token or origin")
+)
+
+// Server is the review web UI:
+type Server struct {
+	cfg       *config.Config
+	store     *store.Store
+	types     *types.Registry
+	processor *processor.Processor
+	logger    zerolog.Logger
+	templates *template.Template
+	// reviewer is the default reviewer name shown in the forms:
+	reviewer string
+	// token is sent with the forms and required to save a correction, as other sites can't read it
+	// they can't submit corrections through the reviewer's browser:
+	token string
+	// lock serializes the requests, corrections update the documents the pages are rendered from:
+	lock sync.Mutex
+}
+
+// Reasons returns why a document needs a human review, empty when it doesn't:
+func Reasons(d *document.Document, minConfidence float64) []string {
+	reasons := make([]string, 0)
+	if d.Type == types.UnknownDocumentType {
+		reasons = append(reasons, "tipo desconocido")
+	}
+	fields := make([]string, 0, len(d.FieldConfidence))
+	for field := range d.FieldConfidence {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		if confidence := d.FieldConfidence[field]; confidence < minConfidence {
+			reasons = append(reasons, fmt.Sprintf("confianza baja en %s (%.2f)", field, confidence))
+		}
+	}
+	if d.Votes != nil {
+		lowConfidence := 0
+		for _, v := range d.Votes.Votes {
+			if v.Confidence > 0 && v.Confidence < minConfidence {
+				lowConfidence++
+			}
+		}
+		if lowConfidence > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d votos con confianza baja", lowConfidence))
+		}
+	}
+	if d.Validation != nil && !d.Validation.Passed() && d.Validation.Override == nil {
+		reasons = append(reasons, fmt.Sprintf("%d errores de validación", len(d.Validation.Errors)))
+	}
+	if len(d.UnresolvedNames) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d nombres sin resolver", len(d.UnresolvedNames)))
+	}
+	if d.Comparison != nil && !d.Comparison.Accepted {
+		reasons = append(reasons, fmt.Sprintf("%d diferencias entre extractores", len(d.Comparison.Disagreements)))
+	}
+	return reasons
+}
+
+// minConfidence returns the configured minimum confidence:
+func (s *Server) minConfidence() float64 {
+	if s.cfg.ReviewConfig.MinConfidence > 0 {
+		return s.cfg.ReviewConfig.MinConfidence
+	}
+	return defaultMinConfidence
+}
+
+// listItem is a document row in the index page:
+type listItem struct {
+	Document *document.Document
+	Reasons  []string
+}
+
+// index lists the documents that need a review, or every document when "todos" is set:
+func (s *Server) index(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	all := r.URL.Query().Get("todos") != ""
+	items := make([]*listItem, 0)
+	for _, d := range s.store.RetrieveDocuments() {
+		reasons := Reasons(d, s.minConfidence())
+		if len(reasons) == 0 && !all {
+			continue
+		}
+		items = append(items, &listItem{Document: d, Reasons: reasons})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Document.ID < items[j].Document.ID
+	})
+	s.render(w, "index.html", map[string]interface{}{
+		"Items": items,
+		"All":   all,
+	})
+}
+
+// voteRow is an editable vote row:
+type voteRow struct {
+	Name       string
+	Option     vote.Option
+	Confidence float64
+	Low        bool
+	Disagree   bool
+}
+
+// documentPage is the data of the document page:
+type documentPage struct {
+	Document      *document.Document
+	Reasons       []string
+	Types         []types.DocumentType
+	Options       []vote.Option
+	Totals        []*total
+	Rows          []*voteRow
+	Disagreements []*document.Disagreement
+	Reviewer      string
+	Token         string
+	Saved         bool
+}
+
+// total is an editable printed total:
+type total struct {
+	Option vote.Option
+	Value  string
+}
+
+// document shows the page images of a document next to its classification and votes, and saves corrections:
+func (s *Server) document(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/documentos/")
+	d := s.store.RetrieveDocument(id)
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !s.sameOrigin(r) {
+			http.Error(w, errForbidden.Error(), http.StatusForbidden)
+			return
+		}
+		if err := s.correct(d, r); errors.Is(err, errForbidden) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
+			s.logger.Err(err).Msgf("error saving review of %s", d.ID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, "/documentos/"+url.PathEscape(d.ID)+"?guardado=1", http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	page := &documentPage{
+		Document: d,
+		Reasons:  Reasons(d, s.minConfidence()),
+		Types:    s.types.Names(),
+		Options:  []vote.Option{vote.Yes, vote.No, vote.Abstention, vote.Absent, vote.NotVoting},
+		Reviewer: s.reviewer,
+		Token:    s.token,
+		Saved:    r.URL.Query().Get("guardado") != "",
+	}
+	disagreeing := make(map[string]bool)
+	if d.Comparison != nil {
+		page.Disagreements = d.Comparison.Disagreements
+		for _, disagreement := range d.Comparison.Disagreements {
+			if disagreement.Name != "" {
+				disagreeing[vote.NormalizeName(disagreement.Name)] = true
+			}
+		}
+	}
+	record := d.Votes
+	if record == nil {
+		record = &vote.Record{}
+	}
+	seen := make(map[vote.Option]bool)
+	for _, option := range page.Options {
+		seen[option] = true
+	}
+	for _, v := range record.Votes {
+		if !seen[v.Option] {
+			seen[v.Option] = true
+			page.Options = append(page.Options, v.Option)
+		}
+		page.Rows = append(page.Rows, &voteRow{
+			Name:       v.Name,
+			Option:     v.Option,
+			Confidence: v.Confidence,
+			Low:        v.Confidence > 0 && v.Confidence < s.minConfidence(),
+			Disagree:   disagreeing[vote.NormalizeName(v.Name)],
+		})
+	}
+	for option := range record.Totals {
+		if !seen[option] {
+			seen[option] = true
+			page.Options = append(page.Options, option)
+		}
+	}
+	for i := 0; i < blankRows; i++ {
+		page.Rows = append(page.Rows, &voteRow{})
+	}
+	for _, option := range page.Options {
+		t := &total{Option: option}
+		if value, ok := record.Totals[option]; ok {
+			t.Value = strconv.Itoa(value)
+		}
+		page.Totals = append(page.Totals, t)
+	}
+	s.render(w, "document.html", page)
+}
+
+// correct parses the review form and applies the correction
+// Rows without a name or option are dropped, so clearing a row removes the vote:
+func (s *Server) correct(d *document.Document, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(r.PostForm.Get("token")), []byte(s.token)) != 1 {
+		return errForbidden
+	}
+	reviewer := strings.TrimSpace(r.PostForm.Get("revisor"))
+	docType := types.DocumentType(r.PostForm.Get("tipo"))
+	var record *vote.Record
+	if r.PostForm.Get("votos") != "" {
+		names, options := r.PostForm["nombre"], r.PostForm["opcion"]
+		if len(names) != len(options) {
+			return errBadForm
+		}
+		record = &vote.Record{
+			Chamber: strings.TrimSpace(r.PostForm.Get("camara")),
+			Date:    strings.TrimSpace(r.PostForm.Get("fecha")),
+			Time:    strings.TrimSpace(r.PostForm.Get("hora")),
+			Subject: strings.TrimSpace(r.PostForm.Get("asunto")),
+			Votes:   make([]*vote.Vote, 0, len(names)),
+		}
+		for key, values := range r.PostForm {
+			option, ok := strings.CutPrefix(key, "total_")
+			if !ok || strings.TrimSpace(values[0]) == "" {
+				continue
+			}
+			value, err := strconv.Atoi(strings.TrimSpace(values[0]))
+			if err != nil {
+				return fmt.Errorf("%w: total %s: %s", errBadForm, option, values[0])
+			}
+			if record.Totals == nil {
+				record.Totals = make(map[vote.Option]int)
+			}
+			record.Totals[vote.Option(option)] = value
+		}
+		for i, name := range names {
+			name = strings.TrimSpace(name)
+			option := vote.Option(options[i])
+			if name == "" || option == "" {
+				continue
+			}
+			v := &vote.Vote{Name: name, Option: option, Confidence: 1}
+			// Keep the extracted confidence of the votes the reviewer didn't change:
+			if d.Votes != nil {
+				if previous := d.Votes.VoteByName(name); previous != nil && previous.Name == name && previous.Option == option {
+					v.Confidence = previous.Confidence
+				}
+			}
+			record.Votes = append(record.Votes, v)
+		}
+	}
+	_, err := s.processor.Correct(d.ID, reviewer, docType, record)
+	return err
+}
+
+// sameOrigin reports if a request comes from the UI itself, requests without Origin
+// and Referer headers are allowed as the form token is checked anyway:
+func (s *Server) sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// image serves a page image, the path is /imagenes/<document ID>/<page index>
+// The preprocessed image is served when "procesada" is set:
+func (s *Server) image(w http.ResponseWriter, r *http.Request) {
+	id, rawPage, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/imagenes/"), "/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	d := s.store.RetrieveDocument(id)
+	page, err := strconv.Atoi(rawPage)
+	if d == nil || err != nil || page < 0 || page >= len(d.ImagePaths) {
+		http.NotFound(w, r)
+		return
+	}
+	path := d.ImagePaths[page]
+	if processed := d.ProcessedImagePath(page); processed != "" && r.URL.Query().Get("procesada") != "" {
+		path = processed
+	}
+	http.ServeFile(w, r, path)
+}
+
+// render executes a template, errors are logged as the response may be partially written:
+func (s *Server) render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+		s.logger.Err(err).Msgf("error rendering %s", name)
+	}
+}
+
+// Handler returns the HTTP handler of the UI:
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.index)
+	mux.HandleFunc("/documentos/", s.document)
+	mux.HandleFunc("/imagenes/", s.image)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe starts the UI on the given address:
+func (s *Server) ListenAndServe(addr string) error {
+	s.logger.Info().Msgf("review UI listening on http://%s", addr)
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+// New initializes the review UI, reviewer is the default reviewer name:
+func New(cfg *config.Config, store *store.Store, registry *types.Registry, processor *processor.Processor, reviewer string, logger zerolog.Logger) (*Server, error) {
+	templates, err := template.New("").Funcs(template.FuncMap{
+		"pathEscape": url.PathEscape,
+		"join":       strings.Join,
+	}).ParseFS(embedded, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+	token := make([]byte, tokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	return &Server{
+		cfg:       cfg,
+		store:     store,
+		types:     registry,
+		processor: processor,
+		reviewer:  reviewer,
+		logger:    logger,
+		templates: templates,
+		token:     hex.EncodeToString(token),
+	}, nil
+}
@@ -0,0 +1,148 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/pdfmeta"
+)
+
+// Fields that can be used in rules:
+const (
+	FieldTitle        = "title"
+	FieldAuthor       = "author"
+	FieldCreator      = "creator"
+	FieldProducer     = "producer"
+	FieldCreationDate = "creation_date"
+	FieldModDate      = "mod_date"
+	FieldPageCount    = "page_count"
+	FieldPageWidth    = "page_width"
+	FieldPageHeight   = "page_height"
+	FieldHasText      = "has_text"
+	FieldHeader       = "header"
+	FieldText         = "text"
+
+	// headerLines is the amount of lines of the first page considered as header:
+	headerLines = 10
+)
+
+var fields = map[string]bool{
+	FieldTitle: true, FieldAuthor: true, FieldCreator: true, FieldProducer: true,
+	FieldCreationDate: true, FieldModDate: true, FieldPageCount: true, FieldPageWidth: true,
+	FieldPageHeight: true, FieldHasText: true, FieldHeader: true, FieldText: true,
+}
+
+// Facts are the field values of a document that rules are evaluated against:
+type Facts map[string]string
+
+// FactsFromMetadata builds the facts of a document, page sizes refer to the first page
+// and has_text is only true when every page has a text layer:
+func FactsFromMetadata(m *pdfmeta.Metadata) Facts {
+	facts := Facts{
+		FieldTitle:        m.Info["Title"],
+		FieldAuthor:       m.Info["Author"],
+		FieldCreator:      m.Info["Creator"],
+		FieldProducer:     m.Info["Producer"],
+		FieldCreationDate: ParseDate(m.Info["CreationDate"]),
+		FieldModDate:      ParseDate(m.Info["ModDate"]),
+		FieldPageCount:    strconv.Itoa(m.PageCount),
+		FieldHasText:      strconv.FormatBool(m.PageCount > 0),
+	}
+	if len(m.Pages) > 0 {
+		facts[FieldPageWidth] = strconv.FormatFloat(m.Pages[0].Width, 'f', 1, 64)
+		facts[FieldPageHeight] = strconv.FormatFloat(m.Pages[0].Height, 'f', 1, 64)
+	}
+	for _, page := range m.Pages {
+		if !page.HasText {
+			facts[FieldHasText] = "false"
+		}
+	}
+	if len(m.Texts) > 0 {
+		lines := strings.Split(m.Texts[0], "\n")
+		if len(lines) > headerLines {
+			lines = lines[:headerLines]
+		}
+		facts[FieldHeader] = strings.Join(lines, "\n")
+		facts[FieldText] = strings.Join(m.Texts, "\n")
+	}
+	return facts
+}
+
+// ParseDate converts a PDF date -e.g. "D:20230720175406-04'00'"- into YYYY-MM-DD,
+// an empty string is returned for invalid dates:
+func ParseDate(pdfDate string) string {
+	date := strings.TrimPrefix(pdfDate, "D:")
+	if len(date) < 8 {
+		return ""
+	}
+	if _, err := strconv.Atoi(date[:8]); err != nil {
+		return ""
+	}
+	return date[:4] + "-" + date[4:6] + "-" + date[6:8]
+}
+
+// Validate checks that the rules use known fields and valid expressions:
+func Validate(rules []config.RuleConfig) error {
+	for _, rule := range rules {
+		if !fields[rule.Field] {
+			return fmt.Errorf("unknown rule field %q", rule.Field)
+		}
+		if rule.Regexp != "" {
+			if _, err := regexp.Compile(rule.Regexp); err != nil {
+				return fmt.Errorf("rule for %q: %w", rule.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Match reports if every rule matches the facts, an empty rule set never matches:
+func Match(rules []config.RuleConfig, facts Facts) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	for _, rule := range rules {
+		if !matchRule(rule, facts[rule.Field]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRule evaluates every condition set in the rule:
+func matchRule(rule config.RuleConfig, value string) bool {
+	if rule.Equals != "" && !strings.EqualFold(value, rule.Equals) {
+		return false
+	}
+	if rule.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(rule.Contains)) {
+		return false
+	}
+	if rule.Regexp != "" {
+		if matched, _ := regexp.MatchString(rule.Regexp, value); !matched {
+			return false
+		}
+	}
+	if rule.Min != nil || rule.Max != nil {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false
+		}
+		if rule.Min != nil && number < *rule.Min {
+			return false
+		}
+		if rule.Max != nil && number > *rule.Max {
+			return false
+		}
+	}
+	// Dates use the YYYY-MM-DD format so they can be compared as strings:
+	if rule.After != "" && (value == "" || value < rule.After) {
+		return false
+	}
+	if rule.Before != "" && (value == "" || value > rule.Before) {
+		return false
+	}
+	return true
+}
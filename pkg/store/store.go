@@ -3,12 +3,18 @@ package store
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"sync"
 
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/cluster"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/legislator"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/pdfmeta"
 	"github.com/matiasinsaurralde/congreso-votaciones/pkg/types"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/usage"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
 	"github.com/rs/zerolog"
 )
 
@@ -16,6 +22,7 @@ import (
 // A vague locking mechanism is used by some methods:
 type Store struct {
 	cfg    *config.Config
+	types  *types.Registry
 	logger zerolog.Logger
 
 	data *Data
@@ -27,10 +34,20 @@ type Store struct {
 // When the store is initialized, it's loaded from disk -if a data file exists-:
 type Data struct {
 	Documents map[string]*document.Document `json:"documents"`
+	// Usage holds the token usage of every model call:
+	Usage []*usage.Record `json:"usage,omitempty"`
+	// Clusters holds the latest clustering of unclassified documents:
+	Clusters []*cluster.Cluster `json:"clusters,omitempty"`
+	// Legislators is the legislator registry:
+	Legislators []*legislator.Person `json:"legislators,omitempty"`
+	// Memberships holds the party and bloc memberships of the legislators:
+	Memberships []*legislator.Membership `json:"memberships,omitempty"`
 }
 
 var (
-	errDocumentNotFound = errors.New("document not found")
+	errDocumentNotFound    = errors.New("document not found")
+	errLegislatorNotFound  = errors.New("legislator not found")
+	errUnknownDocumentType = errors.New("unknown document type")
 )
 
 // Init initializes the store and loads existing data into memory:
@@ -117,8 +134,12 @@ func (s *Store) save() error {
 }
 
 // UpdateDocumentType updates the document type for a given document
-// This is used by the classification step:
-func (s *Store) UpdateDocumentType(id string, docType string) error {
+// This is used by the classification step, info records how the type was obtained
+// An empty type marks the document as unclassified:
+func (s *Store) UpdateDocumentType(id string, docType string, info *document.ResultInfo) error {
+	if docType != "" && !s.types.IsValid(types.DocumentType(docType)) {
+		return fmt.Errorf("%w: %s", errUnknownDocumentType, docType)
+	}
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	doc, ok := s.data.Documents[id]
@@ -126,17 +147,315 @@ func (s *Store) UpdateDocumentType(id string, docType string) error {
 		return errDocumentNotFound
 	}
 	doc.Type = types.DocumentType(docType)
+	doc.Classification = info
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AppendUsage adds a usage record to the store:
+func (s *Store) AppendUsage(r *usage.Record) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Usage = append(s.data.Usage, r)
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RetrieveUsage retrieves all the usage records, optionally filtered by run:
+func (s *Store) RetrieveUsage(runID string) []*usage.Record {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	records := make([]*usage.Record, 0)
+	for _, r := range s.data.Usage {
+		if runID != "" && r.RunID != runID {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// UpdateClusters replaces the stored clusters:
+func (s *Store) UpdateClusters(clusters []*cluster.Cluster) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Clusters = clusters
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RetrieveCluster retrieves a cluster by ID:
+func (s *Store) RetrieveCluster(id int) *cluster.Cluster {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, c := range s.data.Clusters {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// ImportLegislators adds persons to the registry, persons with an existing ID
+// replace its name and their aliases are merged. It returns the amount of new persons:
+func (s *Store) ImportLegislators(persons []*legislator.Person) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	added := 0
+	for _, p := range persons {
+		existing := s.findLegislator(p.ID)
+		if existing == nil {
+			s.data.Legislators = append(s.data.Legislators, p)
+			added++
+			continue
+		}
+		previousName := existing.Name
+		existing.Name = p.Name
+		existing.AddAlias(previousName)
+		for _, alias := range p.Aliases {
+			existing.AddAlias(alias)
+		}
+	}
+	if err := s.save(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// findLegislator returns a person by ID, the lock must be held:
+func (s *Store) findLegislator(id string) *legislator.Person {
+	for _, p := range s.data.Legislators {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// RetrieveLegislators retrieves the legislator registry:
+func (s *Store) RetrieveLegislators() []*legislator.Person {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*legislator.Person{}, s.data.Legislators...)
+}
+
+// RetrieveLegislator retrieves a person by ID:
+func (s *Store) RetrieveLegislator(id string) *legislator.Person {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.findLegislator(id)
+}
+
+// AddLegislatorAlias adds an alias to a person:
+func (s *Store) AddLegislatorAlias(id string, alias string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	p := s.findLegislator(id)
+	if p == nil {
+		return errLegislatorNotFound
+	}
+	if !p.AddAlias(alias) {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ImportMemberships adds memberships, a membership with the same key -see legislator.Membership.Key-
+// replaces the existing one. Every person must be in the registry. It returns the amount of new memberships:
+func (s *Store) ImportMemberships(memberships []*legislator.Membership) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, m := range memberships {
+		if s.findLegislator(m.PersonID) == nil {
+			return 0, fmt.Errorf("%w: %s", errLegislatorNotFound, m.PersonID)
+		}
+	}
+	existing := make(map[string]int)
+	for i, m := range s.data.Memberships {
+		existing[m.Key()] = i
+	}
+	added := 0
+	for _, m := range memberships {
+		if i, ok := existing[m.Key()]; ok {
+			s.data.Memberships[i] = m
+			continue
+		}
+		existing[m.Key()] = len(s.data.Memberships)
+		s.data.Memberships = append(s.data.Memberships, m)
+		added++
+	}
+	if err := s.save(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
+
+// RetrieveMemberships retrieves every membership:
+func (s *Store) RetrieveMemberships() []*legislator.Membership {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*legislator.Membership{}, s.data.Memberships...)
+}
+
+// UpdateDocumentLegislators replaces the votes of a document with the same votes
+// linked to the legislator registry, and sets the names that couldn't be resolved:
+func (s *Store) UpdateDocumentLegislators(id string, votes []*vote.Vote, unresolved []*document.UnresolvedName) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	if doc.Votes == nil || len(doc.Votes.Votes) != len(votes) {
+		return fmt.Errorf("%s: votes changed while resolving legislators", id)
+	}
+	doc.Votes.Votes = votes
+	doc.UnresolvedNames = unresolved
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDocumentVotes sets the extracted votes of a document
+// This is used by the extraction step, jsonPath is where the votes were written to:
+func (s *Store) UpdateDocumentVotes(id string, jsonPath string, result *document.ExtractionResult) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	doc.Votes = result.Votes
+	doc.JSONPath = jsonPath
+	doc.Extraction = result.Info
+	doc.FieldConfidence = result.Confidence
+	doc.Evidence = result.Evidence
+	// The votes changed so the previous validation and comparison no longer apply:
+	doc.Validation = nil
+	doc.Extractions = nil
+	doc.Comparison = nil
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDocumentMetadata sets the PDF metadata of a document:
+func (s *Store) UpdateDocumentMetadata(id string, metadata *pdfmeta.Metadata) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	doc.Metadata = metadata
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDocumentProcessedImages sets the preprocessed page images of a document:
+func (s *Store) UpdateDocumentProcessedImages(id string, images []*document.ProcessedImage) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	doc.ProcessedImages = images
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDocumentComparison sets the ensemble results of a document and their comparison:
+func (s *Store) UpdateDocumentComparison(id string, results []*document.ExtractionResult, comparison *document.Comparison) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	doc.Extractions = results
+	doc.Comparison = comparison
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddDocumentReview applies a reviewer correction: the type and votes are replaced, ensemble
+// disagreements are marked as resolved and the review is appended to the document
+// A type change without votes drops the extraction of the previous type so the document is extracted again:
+func (s *Store) AddDocumentReview(id string, docType types.DocumentType, votes *vote.Record, jsonPath string, review *document.Review) error {
+	if !s.types.IsValid(docType) {
+		return fmt.Errorf("%w: %s", errUnknownDocumentType, docType)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	if votes == nil && docType != doc.Type {
+		doc.Votes = nil
+		doc.JSONPath = ""
+		doc.Extraction = nil
+		doc.FieldConfidence = nil
+		doc.Evidence = nil
+		doc.Validation = nil
+		doc.Extractions = nil
+		doc.Comparison = nil
+		doc.UnresolvedNames = nil
+	}
+	doc.Type = docType
+	if votes != nil {
+		doc.Votes = votes
+		doc.JSONPath = jsonPath
+		if doc.Comparison != nil {
+			doc.Comparison.Accepted = true
+		}
+	}
+	doc.Reviews = append(doc.Reviews, review)
+	if err := s.save(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UpdateDocumentValidation sets the validation result of a document:
+func (s *Store) UpdateDocumentValidation(id string, validation *document.Validation) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	doc, ok := s.data.Documents[id]
+	if !ok {
+		return errDocumentNotFound
+	}
+	doc.Validation = validation
 	if err := s.save(); err != nil {
 		return err
 	}
 	return nil
 }
 
-// New creates a new store with the given config and logger:
-func New(cfg *config.Config, logger zerolog.Logger) *Store {
+// New creates a new store with the given config, document types and logger:
+func New(cfg *config.Config, registry *types.Registry, logger zerolog.Logger) *Store {
 	s := &Store{
 		lock:   &sync.Mutex{},
 		cfg:    cfg,
+		types:  registry,
 		logger: logger,
 	}
 	return s
@@ -0,0 +1,355 @@
+package table
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+)
+
+const (
+	// rulingTolerance merges rulings and aligned text closer than this distance -relative to the page-:
+	rulingTolerance = 0.005
+	// edgeTolerance is the distance a segment can start before a column limit and still belong to the column:
+	edgeTolerance = 0.001
+	// alignmentTolerance is the distance between left edges considered aligned:
+	alignmentTolerance = 0.01
+	// minAlignment is the amount of aligned segments needed to start a column:
+	minAlignment = 2
+	// segmentGap is the gap between chars, relative to the line height, that splits a line in segments:
+	segmentGap = 1.5
+)
+
+// Table is the cell grid of a page:
+type Table struct {
+	// Columns are the column limits, there's one more limit than columns:
+	Columns []float64
+	// Ruled is set when rows and columns come from ruling lines instead of text alignment:
+	Ruled bool
+	Rows  []*Row
+}
+
+// Row is a table row, it has a cell for every column:
+type Row struct {
+	Top    float64
+	Bottom float64
+	Cells  []*Cell
+}
+
+// Cell is a table cell, ruled cells may hold multiple lines:
+type Cell struct {
+	Row    int
+	Column int
+	Lines  []*Line
+}
+
+// Line is a line of text inside a cell:
+type Line struct {
+	Text   string
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+}
+
+// Text returns the lines of the cell joined by spaces:
+func (c *Cell) Text() string {
+	lines := make([]string, 0, len(c.Lines))
+	for _, l := range c.Lines {
+		lines = append(lines, l.Text)
+	}
+	return strings.Join(lines, " ")
+}
+
+// Empty reports if the cell has no text:
+func (c *Cell) Empty() bool {
+	return len(c.Lines) == 0
+}
+
+// Text returns the text of the non empty cells of the row:
+func (r *Row) Text() string {
+	texts := make([]string, 0, len(r.Cells))
+	for _, c := range r.Cells {
+		if !c.Empty() {
+			texts = append(texts, c.Text())
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+// Detect rebuilds the table structure of a page
+// Columns and rows are taken from the ruling lines when the page has them,
+// otherwise columns come from the alignment of text segments and every line is a row:
+func Detect(page *pdf2png.PageLayout) *Table {
+	segments := make([]*Line, 0)
+	for _, chars := range groupLines(charsOf(page)) {
+		segments = append(segments, splitSegments(chars, page.Width/page.Height)...)
+	}
+	horizontal, vertical := rulingPositions(page.Rulings, segments)
+	t := &Table{
+		Ruled: len(vertical) > 1 && len(horizontal) > 1,
+	}
+	if len(vertical) > 1 {
+		t.Columns = limits(vertical)
+	} else {
+		t.Columns = limits(alignedColumns(segments))
+	}
+	t.dropEmptyColumns(segments)
+	t.buildRows(segments, horizontal)
+	return t
+}
+
+// char is a non space character, space is set when it follows a space in the text layer:
+type char struct {
+	pdf2png.TextBox
+	space bool
+}
+
+// charsOf returns the non space characters of a page:
+func charsOf(page *pdf2png.PageLayout) []char {
+	chars := make([]char, 0, len(page.Chars))
+	space := false
+	for _, c := range page.Chars {
+		if strings.TrimSpace(c.Text) == "" {
+			space = true
+			continue
+		}
+		chars = append(chars, char{TextBox: c, space: space})
+		space = false
+	}
+	return chars
+}
+
+// dropEmptyColumns merges the columns where no segment starts into their left neighbour,
+// double borders and decorations produce narrow empty columns:
+func (t *Table) dropEmptyColumns(segments []*Line) {
+	used := make(map[int]bool)
+	for _, s := range segments {
+		used[t.column(s.Left)] = true
+	}
+	columns := []float64{0}
+	first := true
+	for i := 0; i < len(t.Columns)-1; i++ {
+		if !used[i] {
+			continue
+		}
+		if !first {
+			columns = append(columns, t.Columns[i])
+		}
+		first = false
+	}
+	t.Columns = append(columns, 1)
+}
+
+// column returns the column of a position:
+func (t *Table) column(x float64) int {
+	for i := len(t.Columns) - 2; i > 0; i-- {
+		if x+edgeTolerance >= t.Columns[i] {
+			return i
+		}
+	}
+	return 0
+}
+
+// buildRows groups segments in rows, segments between the same pair of horizontal
+// rulings share a row. Outside of the ruled area every line is a row:
+func (t *Table) buildRows(segments []*Line, horizontal []float64) {
+	sort.SliceStable(segments, func(i, j int) bool {
+		return center(segments[i]) < center(segments[j])
+	})
+	var current *Row
+	var previous *Line
+	for _, s := range segments {
+		if current == nil || !t.sameRow(previous, s, horizontal) {
+			current = &Row{
+				Top:    s.Top,
+				Bottom: s.Bottom,
+				Cells:  make([]*Cell, len(t.Columns)-1),
+			}
+			for i := range current.Cells {
+				current.Cells[i] = &Cell{Row: len(t.Rows), Column: i}
+			}
+			t.Rows = append(t.Rows, current)
+		}
+		cell := current.Cells[t.column(s.Left)]
+		cell.Lines = append(cell.Lines, s)
+		current.Top = min(current.Top, s.Top)
+		current.Bottom = max(current.Bottom, s.Bottom)
+		previous = s
+	}
+	for _, r := range t.Rows {
+		for _, c := range r.Cells {
+			sort.SliceStable(c.Lines, func(i, j int) bool {
+				return c.Lines[i].Top < c.Lines[j].Top-rulingTolerance ||
+					(c.Lines[i].Top < c.Lines[j].Top+rulingTolerance && c.Lines[i].Left < c.Lines[j].Left)
+			})
+		}
+	}
+}
+
+// sameRow reports if two segments belong to the same row:
+func (t *Table) sameRow(a, b *Line, horizontal []float64) bool {
+	ya, yb := center(a), center(b)
+	// Segments on the same line:
+	if yb <= a.Bottom {
+		return true
+	}
+	if !t.Ruled || ya < horizontal[0] || yb > horizontal[len(horizontal)-1] {
+		return false
+	}
+	for _, y := range horizontal {
+		if y > ya && y < yb {
+			return false
+		}
+	}
+	return true
+}
+
+// rulingPositions returns the sorted positions of the horizontal and vertical rulings,
+// close rulings -double borders- are merged. Vertical rulings that cross text are
+// shading or decorations rather than cell borders and are ignored:
+func rulingPositions(rulings []pdf2png.Ruling, segments []*Line) ([]float64, []float64) {
+	horizontal, vertical := make([]float64, 0), make([]float64, 0)
+	for _, r := range rulings {
+		if r.Horizontal {
+			horizontal = append(horizontal, r.Position)
+			continue
+		}
+		if !crossesText(r, segments) {
+			vertical = append(vertical, r.Position)
+		}
+	}
+	return merge(horizontal, rulingTolerance), merge(vertical, rulingTolerance)
+}
+
+// crossesText reports if a vertical ruling goes through a text segment:
+func crossesText(r pdf2png.Ruling, segments []*Line) bool {
+	for _, s := range segments {
+		y := center(s)
+		if y > r.Start && y < r.End && r.Position > s.Left+rulingTolerance && r.Position < s.Right-rulingTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// merge sorts positions and replaces groups of close positions by their first one:
+func merge(positions []float64, tolerance float64) []float64 {
+	sort.Float64s(positions)
+	merged := make([]float64, 0, len(positions))
+	for i, p := range positions {
+		if i > 0 && p-positions[i-1] <= tolerance {
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// alignedColumns returns the left edges shared by at least minAlignment segments:
+func alignedColumns(segments []*Line) []float64 {
+	lefts := make([]float64, 0, len(segments))
+	for _, s := range segments {
+		lefts = append(lefts, s.Left)
+	}
+	sort.Float64s(lefts)
+	columns := make([]float64, 0)
+	start, count := 0, 0
+	for i := range lefts {
+		if i > 0 && lefts[i]-lefts[i-1] > alignmentTolerance {
+			if count >= minAlignment {
+				columns = append(columns, lefts[start])
+			}
+			start, count = i, 0
+		}
+		count++
+	}
+	if count >= minAlignment {
+		columns = append(columns, lefts[start])
+	}
+	return columns
+}
+
+// limits returns the column limits for the given column starts, covering the whole page:
+func limits(starts []float64) []float64 {
+	columns := []float64{0}
+	for _, x := range starts {
+		if x > columns[len(columns)-1]+rulingTolerance && x < 1-rulingTolerance {
+			columns = append(columns, x)
+		}
+	}
+	return append(columns, 1)
+}
+
+// groupLines groups chars that overlap vertically, chars are sorted from left to right:
+func groupLines(chars []char) [][]char {
+	sort.SliceStable(chars, func(i, j int) bool {
+		return (chars[i].Top+chars[i].Bottom)/2 < (chars[j].Top+chars[j].Bottom)/2
+	})
+	lines := make([][]char, 0)
+	var top, bottom float64
+	for _, c := range chars {
+		// Chars overlapping most of the line extent belong to it, superscripts and quotes included:
+		if n := len(lines); n > 0 {
+			overlap := min(bottom, c.Bottom) - max(top, c.Top)
+			if overlap > min(bottom-top, c.Bottom-c.Top)/2 {
+				lines[n-1] = append(lines[n-1], c)
+				top, bottom = min(top, c.Top), max(bottom, c.Bottom)
+				continue
+			}
+		}
+		lines = append(lines, []char{c})
+		top, bottom = c.Top, c.Bottom
+	}
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool {
+			return line[i].Left < line[j].Left
+		})
+	}
+	return lines
+}
+
+// splitSegments splits a line of chars at gaps larger than segmentGap times the line height
+// Positions are relative to the page so horizontal gaps are scaled by the page aspect ratio:
+func splitSegments(chars []char, aspect float64) []*Line {
+	height := 0.0
+	for _, c := range chars {
+		height = max(height, c.Bottom-c.Top)
+	}
+	segments := make([]*Line, 0)
+	var current *Line
+	var text strings.Builder
+	flush := func() {
+		if current != nil {
+			current.Text = text.String()
+			segments = append(segments, current)
+		}
+		text.Reset()
+	}
+	for i, c := range chars {
+		if i > 0 {
+			gap := (c.Left - chars[i-1].Right) * aspect
+			switch {
+			case gap > height*segmentGap:
+				flush()
+				current = nil
+			case c.space:
+				text.WriteString(" ")
+			}
+		}
+		if current == nil {
+			current = &Line{Left: c.Left, Top: c.Top, Right: c.Right, Bottom: c.Bottom}
+		}
+		text.WriteString(c.Text)
+		current.Top = min(current.Top, c.Top)
+		current.Right = max(current.Right, c.Right)
+		current.Bottom = max(current.Bottom, c.Bottom)
+	}
+	flush()
+	return segments
+}
+
+// center returns the vertical center of a line:
+func center(l *Line) float64 {
+	return (l.Top + l.Bottom) / 2
+}
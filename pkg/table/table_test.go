@@ -0,0 +1,158 @@
+package table
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/pdf2png"
+)
+
+const (
+	charWidth  = 0.008
+	charHeight = 0.012
+)
+
+// text returns the char boxes of a string starting at the given position, spaces included:
+func text(s string, left, top float64) []pdf2png.TextBox {
+	chars := make([]pdf2png.TextBox, 0, len(s))
+	for i, r := range []rune(s) {
+		x := left + float64(i)*charWidth
+		chars = append(chars, pdf2png.TextBox{Text: string(r), Left: x, Top: top, Right: x + charWidth, Bottom: top + charHeight})
+	}
+	return chars
+}
+
+// rulings returns horizontal or vertical rulings at the given positions, spanning from start to end:
+func rulings(horizontal bool, start, end float64, positions ...float64) []pdf2png.Ruling {
+	out := make([]pdf2png.Ruling, 0, len(positions))
+	for _, p := range positions {
+		out = append(out, pdf2png.Ruling{Horizontal: horizontal, Position: p, Start: start, End: end})
+	}
+	return out
+}
+
+// page builds a square page layout:
+func page(chars [][]pdf2png.TextBox, rulings ...[]pdf2png.Ruling) *pdf2png.PageLayout {
+	p := &pdf2png.PageLayout{Width: 600, Height: 600}
+	for _, c := range chars {
+		p.Chars = append(p.Chars, c...)
+	}
+	for _, r := range rulings {
+		p.Rulings = append(p.Rulings, r...)
+	}
+	return p
+}
+
+// cellTexts returns the text of every cell, row by row:
+func cellTexts(t *Table) [][]string {
+	rows := make([][]string, 0, len(t.Rows))
+	for _, r := range t.Rows {
+		cells := make([]string, 0, len(r.Cells))
+		for _, c := range r.Cells {
+			cells = append(cells, c.Text())
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		page    *pdf2png.PageLayout
+		ruled   bool
+		columns int
+		rows    [][]string
+	}{
+		{
+			// Rows come from the horizontal rulings, so a wrapped name stays in one cell
+			// The shading line through "Juan Perez" isn't a cell border:
+			name: "ruled grid",
+			page: page(
+				[][]pdf2png.TextBox{
+					text("Planilla de votacion", 0.15, 0.05),
+					text("Nombre", 0.12, 0.12),
+					text("Voto", 0.42, 0.12),
+					text("Juan Perez", 0.12, 0.17),
+					text("Si", 0.42, 0.17),
+					text("Ana Maria", 0.12, 0.205),
+					text("Gomez", 0.12, 0.225),
+					text("No", 0.42, 0.215),
+				},
+				rulings(true, 0.1, 0.7, 0.1, 0.15, 0.2, 0.25),
+				rulings(false, 0.1, 0.25, 0.1, 0.4, 0.7),
+				rulings(false, 0.165, 0.19, 0.15),
+			),
+			ruled:   true,
+			columns: 2,
+			rows: [][]string{
+				{"Planilla de votacion", ""},
+				{"Nombre", "Voto"},
+				{"Juan Perez", "Si"},
+				{"Ana Maria Gomez", "No"},
+			},
+		},
+		{
+			// Without rulings the columns are the left edges shared by several segments
+			// and every line is a row:
+			name: "aligned text",
+			page: page([][]pdf2png.TextBox{
+				text("Resultado", 0.3, 0.05),
+				text("Juan Perez", 0.1, 0.1),
+				text("Si", 0.5, 0.1),
+				text("Ana Gomez", 0.1, 0.13),
+				text("No", 0.505, 0.13),
+				text("Luis Benitez", 0.1, 0.16),
+				text("Si", 0.5, 0.16),
+			}),
+			columns: 2,
+			rows: [][]string{
+				{"Resultado", ""},
+				{"Juan Perez", "Si"},
+				{"Ana Gomez", "No"},
+				{"Luis Benitez", "Si"},
+			},
+		},
+		{
+			// The close borders are merged and the narrow column between the far ones is dropped:
+			name: "double border",
+			page: page(
+				[][]pdf2png.TextBox{
+					text("Juan Perez", 0.12, 0.12),
+					text("Si", 0.42, 0.12),
+					text("Ana Gomez", 0.12, 0.17),
+					text("No", 0.42, 0.17),
+				},
+				rulings(true, 0.1, 0.7, 0.1, 0.102, 0.15, 0.2, 0.203),
+				rulings(false, 0.1, 0.2, 0.1, 0.103, 0.4, 0.408, 0.7, 0.702),
+			),
+			ruled:   true,
+			columns: 2,
+			rows: [][]string{
+				{"Juan Perez", "Si"},
+				{"Ana Gomez", "No"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := Detect(tt.page)
+			if table.Ruled != tt.ruled {
+				t.Errorf("ruled = %v, want %v", table.Ruled, tt.ruled)
+			}
+			if columns := len(table.Columns) - 1; columns != tt.columns {
+				t.Errorf("expected %d columns, got %d: %v", tt.columns, columns, table.Columns)
+			}
+			if got := cellTexts(table); fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.rows) {
+				t.Errorf("rows = %q, want %q", got, tt.rows)
+			}
+			for i, r := range table.Rows {
+				for j, c := range r.Cells {
+					if c.Row != i || c.Column != j {
+						t.Errorf("cell at %d,%d has position %d,%d", i, j, c.Row, c.Column)
+					}
+				}
+			}
+		})
+	}
+}
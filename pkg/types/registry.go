@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/rules"
+)
+
+// nameRe restricts type names so they're safe to use in file names and URLs:
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Definition describes a document type:
+type Definition struct {
+	// Name is the type name, stored in every classified document:
+	Name DocumentType `json:"name"`
+	// Description is a human readable description, also passed to the classifier:
+	Description string `json:"description,omitempty"`
+	// Samples are the sample file names, relative to the samples path:
+	Samples []string `json:"samples"`
+	// Hints are additional instructions for the classifier:
+	Hints string `json:"hints,omitempty"`
+	// Chamber is the default chamber of documents of this type:
+	Chamber string `json:"chamber,omitempty"`
+	// Extractors is the ordered chain of extractors that handle this type:
+	Extractors []string `json:"extractors,omitempty"`
+	// Rules are used by the rule based classifier:
+	Rules []config.RuleConfig `json:"rules,omitempty"`
+}
+
+// Registry holds the document types loaded from the configuration:
+type Registry struct {
+	cfg         *config.Config
+	definitions map[DocumentType]*Definition
+}
+
+// NewRegistry loads the document types from the "document_types" config block
+// Labels from the legacy "sample_data" block are registered as types with samples only:
+func NewRegistry(cfg *config.Config) (*Registry, error) {
+	r := &Registry{
+		cfg:         cfg,
+		definitions: make(map[DocumentType]*Definition),
+	}
+	for _, typeConfig := range cfg.DocumentTypes {
+		name := DocumentType(typeConfig.Name)
+		if _, exists := r.definitions[name]; exists {
+			return nil, fmt.Errorf("document type %q is defined more than once", name)
+		}
+		r.definitions[name] = &Definition{
+			Name:        name,
+			Description: typeConfig.Description,
+			Samples:     typeConfig.Samples,
+			Hints:       typeConfig.Hints,
+			Chamber:     typeConfig.Chamber,
+			Extractors:  extractorChain(typeConfig),
+			Rules:       typeConfig.Rules,
+		}
+	}
+	for label, samples := range cfg.SampleData {
+		name := DocumentType(label)
+		if definition, exists := r.definitions[name]; exists {
+			definition.Samples = append(definition.Samples, samples...)
+			continue
+		}
+		r.definitions[name] = &Definition{
+			Name:    name,
+			Samples: samples,
+		}
+	}
+	return r, nil
+}
+
+// extractorChain combines the "extractor" and "extractors" settings:
+func extractorChain(typeConfig config.DocumentTypeConfig) []string {
+	chain := make([]string, 0, len(typeConfig.Extractors)+1)
+	if typeConfig.Extractor != "" {
+		chain = append(chain, typeConfig.Extractor)
+	}
+	for _, name := range typeConfig.Extractors {
+		if name != typeConfig.Extractor {
+			chain = append(chain, name)
+		}
+	}
+	return chain
+}
+
+// Validate checks the type names and makes sure every sample exists:
+func (r *Registry) Validate() error {
+	for _, name := range r.Names() {
+		definition := r.definitions[name]
+		if err := ValidateName(name); err != nil {
+			return err
+		}
+		if err := rules.Validate(definition.Rules); err != nil {
+			return fmt.Errorf("document type %q: %w", name, err)
+		}
+		for _, sample := range definition.Samples {
+			if _, err := os.Stat(filepath.Join(r.cfg.SamplesPath, sample)); err != nil {
+				return fmt.Errorf("document type %q: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateName checks that a name can be used for a new document type:
+func ValidateName(name DocumentType) error {
+	if !nameRe.MatchString(string(name)) {
+		return fmt.Errorf("invalid document type name %q", name)
+	}
+	if name == UnknownDocumentType {
+		return fmt.Errorf("document type name %q is reserved", name)
+	}
+	return nil
+}
+
+// Get returns the definition of a given type, nil if it's not registered:
+func (r *Registry) Get(name DocumentType) *Definition {
+	return r.definitions[name]
+}
+
+// IsValid reports if a type is registered, the unknown type is always valid:
+func (r *Registry) IsValid(name DocumentType) bool {
+	if name == UnknownDocumentType {
+		return true
+	}
+	_, ok := r.definitions[name]
+	return ok
+}
+
+// Names returns the sorted list of registered type names:
+func (r *Registry) Names() []DocumentType {
+	names := make([]DocumentType, 0, len(r.definitions))
+	for name := range r.definitions {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Definitions returns every registered definition, sorted by name:
+func (r *Registry) Definitions() []*Definition {
+	definitions := make([]*Definition, 0, len(r.definitions))
+	for _, name := range r.Names() {
+		definitions = append(definitions, r.definitions[name])
+	}
+	return definitions
+}
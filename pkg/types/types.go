@@ -3,10 +3,7 @@ package types
 // DocumentType is a type alias for the document type:
 type DocumentType string
 
-// Some hardcoded document type constants:
+// UnknownDocumentType is set when a document doesn't match any registered type:
 const (
-	DocumentTypeA       DocumentType = "a"
-	DocumentTypeB       DocumentType = "b"
-	DocumentTypeC       DocumentType = "c"
 	UnknownDocumentType DocumentType = "unknown"
 )
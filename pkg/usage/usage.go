@@ -0,0 +1,188 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/internal/pkg/openai"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+)
+
+// Stage names used for attributing usage:
+const (
+	StageClassification = "classification"
+	StageExtraction     = "extraction"
+)
+
+var (
+	// ErrBudgetExceeded is returned once the run cost goes over the configured cap:
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// ErrNoPricing is returned when a spend cap is set but the model has no price, so the cap can't be enforced:
+	ErrNoPricing = errors.New("no pricing for model")
+)
+
+// defaultPricing holds the USD prices per 1K tokens for known models
+// These can be overriden using the "pricing" config block:
+var defaultPricing = map[string]config.ModelPricing{
+	"gpt-4-vision-preview": {PromptPer1K: 0.01, CompletionPer1K: 0.03},
+	"gpt-4-1106-preview":   {PromptPer1K: 0.01, CompletionPer1K: 0.03},
+	"gpt-4":                {PromptPer1K: 0.03, CompletionPer1K: 0.06},
+	"gpt-3.5-turbo-1106":   {PromptPer1K: 0.001, CompletionPer1K: 0.002},
+}
+
+// Record is the usage of a single completion call:
+type Record struct {
+	RunID            string    `json:"run_id"`
+	DocumentID       string    `json:"document_id"`
+	Stage            string    `json:"stage"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	Cached           bool      `json:"cached"`
+	Timestamp        time.Time `json:"timestamp"`
+}
+
+// Totals aggregates token counts and cost:
+type Totals struct {
+	Calls            int     `json:"calls"`
+	CachedCalls      int     `json:"cached_calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// add accumulates a record into the totals:
+func (t *Totals) add(r *Record) {
+	t.Calls++
+	if r.Cached {
+		t.CachedCalls++
+	}
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.Cost += r.Cost
+}
+
+// String returns a short human readable version of the totals:
+func (t *Totals) String() string {
+	return fmt.Sprintf("%d calls (%d cached), %d prompt tokens, %d completion tokens, $%.4f",
+		t.Calls, t.CachedCalls, t.PromptTokens, t.CompletionTokens, t.Cost)
+}
+
+// Summary is the aggregated usage for a set of records:
+type Summary struct {
+	Totals
+	ByStage    map[string]*Totals `json:"by_stage"`
+	ByDocument map[string]*Totals `json:"by_document"`
+}
+
+// Summarize aggregates the given records:
+func Summarize(records []*Record) *Summary {
+	s := &Summary{
+		ByStage:    make(map[string]*Totals),
+		ByDocument: make(map[string]*Totals),
+	}
+	for _, r := range records {
+		s.Totals.add(r)
+		if s.ByStage[r.Stage] == nil {
+			s.ByStage[r.Stage] = &Totals{}
+		}
+		s.ByStage[r.Stage].add(r)
+		if s.ByDocument[r.DocumentID] == nil {
+			s.ByDocument[r.DocumentID] = &Totals{}
+		}
+		s.ByDocument[r.DocumentID].add(r)
+	}
+	return s
+}
+
+// Tracker keeps track of the usage of a single run
+// and enforces the configured spend cap:
+type Tracker struct {
+	cfg     *config.Config
+	runID   string
+	records []*Record
+	lock    *sync.Mutex
+}
+
+// Priced reports if a model has a price, either configured or a default one:
+func (t *Tracker) Priced(model string) bool {
+	if _, ok := t.cfg.OpenAIConfig.Pricing[model]; ok {
+		return true
+	}
+	_, ok := defaultPricing[model]
+	return ok
+}
+
+// CheckPricing returns ErrNoPricing when a spend cap is set and the model has no price
+// Without a cap unpriced calls are allowed, their cost is reported as zero:
+func (t *Tracker) CheckPricing(model string) error {
+	if t.cfg.OpenAIConfig.MaxCost <= 0 || t.Priced(model) {
+		return nil
+	}
+	return fmt.Errorf("%w %s, add it to the openai pricing config to enforce max_cost", ErrNoPricing, model)
+}
+
+// Cost calculates the estimated cost of the given usage for a model, unpriced models cost zero:
+func (t *Tracker) Cost(model string, u openai.Usage) float64 {
+	pricing, ok := t.cfg.OpenAIConfig.Pricing[model]
+	if !ok {
+		pricing = defaultPricing[model]
+	}
+	return float64(u.PromptTokens)/1000*pricing.PromptPer1K +
+		float64(u.CompletionTokens)/1000*pricing.CompletionPer1K
+}
+
+// Add records the usage of a completion call, cached responses don't add any cost:
+func (t *Tracker) Add(documentID, stage, model string, u openai.Usage, cached bool) *Record {
+	r := &Record{
+		RunID:            t.runID,
+		DocumentID:       documentID,
+		Stage:            stage,
+		Model:            model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Cached:           cached,
+		Timestamp:        time.Now(),
+	}
+	if !cached {
+		r.Cost = t.Cost(model, u)
+	}
+	t.lock.Lock()
+	t.records = append(t.records, r)
+	t.lock.Unlock()
+	return r
+}
+
+// Exceeded reports if the run cost is over the spend cap:
+func (t *Tracker) Exceeded() bool {
+	maxCost := t.cfg.OpenAIConfig.MaxCost
+	if maxCost <= 0 {
+		return false
+	}
+	return t.Summary().Cost >= maxCost
+}
+
+// Summary returns the aggregated usage for the current run:
+func (t *Tracker) Summary() *Summary {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return Summarize(t.records)
+}
+
+// RunID returns the current run identifier:
+func (t *Tracker) RunID() string {
+	return t.runID
+}
+
+// NewTracker initializes a tracker for a new run:
+func NewTracker(cfg *config.Config) *Tracker {
+	return &Tracker{
+		cfg:     cfg,
+		runID:   time.Now().UTC().Format("20060102T150405Z"),
+		records: make([]*Record, 0),
+		lock:    &sync.Mutex{},
+	}
+}
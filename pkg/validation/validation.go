@@ -0,0 +1,197 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/document"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// Names of the checks, stored in document.ValidationError.Check:
+const (
+	CheckTotals      = "totals"
+	CheckDuplicates  = "duplicates"
+	CheckChamberSize = "chamber_size"
+	CheckDate        = "date"
+)
+
+// dateLayout is the layout of the record and period dates:
+const dateLayout = "2006-01-02"
+
+// earliestDate is the oldest plausible vote date when no legislative periods are set,
+// the current constitution was sanctioned in 1992:
+var earliestDate = time.Date(1992, time.June, 20, 0, 0, 0, 0, time.UTC)
+
+// period is a parsed legislative period:
+type period struct {
+	name     string
+	start    time.Time
+	end      time.Time
+	chambers map[string]int
+}
+
+// Validator checks extracted vote records:
+type Validator struct {
+	periods []*period
+	// now returns the current time, used to reject future dates:
+	now func() time.Time
+}
+
+// New parses the legislative periods set in the configuration:
+func New(cfg *config.Config) (*Validator, error) {
+	v := &Validator{now: time.Now}
+	for _, periodConfig := range cfg.LegislativePeriods {
+		start, err := time.Parse(dateLayout, periodConfig.Start)
+		if err != nil {
+			return nil, fmt.Errorf("legislative period %s: %w", periodConfig.Name, err)
+		}
+		end, err := time.Parse(dateLayout, periodConfig.End)
+		if err != nil {
+			return nil, fmt.Errorf("legislative period %s: %w", periodConfig.Name, err)
+		}
+		if end.Before(start) {
+			return nil, fmt.Errorf("legislative period %s ends before it starts", periodConfig.Name)
+		}
+		v.periods = append(v.periods, &period{
+			name:     periodConfig.Name,
+			start:    start,
+			end:      end,
+			chambers: periodConfig.Chambers,
+		})
+	}
+	return v, nil
+}
+
+// Validate runs every check over a record and returns the failed ones
+// The chamber is used when the record doesn't state it:
+func (v *Validator) Validate(record *vote.Record, chamber string) []*document.ValidationError {
+	if record.Chamber != "" {
+		chamber = record.Chamber
+	}
+	errs := make([]*document.ValidationError, 0)
+	errs = append(errs, checkTotals(record)...)
+	errs = append(errs, checkDuplicates(record)...)
+	date, err := v.checkDate(record)
+	if err != nil {
+		errs = append(errs, &document.ValidationError{Check: CheckDate, Message: err.Error()})
+	}
+	if !date.IsZero() {
+		if err := v.checkChamberSize(record, date, chamber); err != nil {
+			errs = append(errs, &document.ValidationError{Check: CheckChamberSize, Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// checkTotals compares the votes per option with the printed totals, options
+// without a printed total aren't checked:
+func checkTotals(record *vote.Record) []*document.ValidationError {
+	counts := record.Count()
+	options := make([]string, 0, len(record.Totals))
+	for option := range record.Totals {
+		options = append(options, string(option))
+	}
+	sort.Strings(options)
+	errs := make([]*document.ValidationError, 0)
+	for _, option := range options {
+		total, count := record.Totals[vote.Option(option)], counts[vote.Option(option)]
+		if total == count {
+			continue
+		}
+		errs = append(errs, &document.ValidationError{
+			Check:   CheckTotals,
+			Message: fmt.Sprintf("%s: %d votes but the printed total is %d", option, count, total),
+		})
+	}
+	return errs
+}
+
+// checkDuplicates reports legislators that appear more than once, names are compared using vote.NormalizeName:
+func checkDuplicates(record *vote.Record) []*document.ValidationError {
+	seen := make(map[string]*vote.Vote)
+	errs := make([]*document.ValidationError, 0)
+	for _, v := range record.Votes {
+		normalized := vote.NormalizeName(v.Name)
+		first, ok := seen[normalized]
+		if !ok {
+			seen[normalized] = v
+			continue
+		}
+		errs = append(errs, &document.ValidationError{
+			Check:   CheckDuplicates,
+			Message: fmt.Sprintf("%s appears twice: %s and %s", v.Name, first.Option, v.Option),
+		})
+	}
+	return errs
+}
+
+// checkDate parses the record date and checks it's plausible, the parsed date
+// is returned even if it falls outside the legislative periods:
+func (v *Validator) checkDate(record *vote.Record) (time.Time, error) {
+	if record.Date == "" {
+		return time.Time{}, errors.New("missing date")
+	}
+	date, err := time.Parse(dateLayout, record.Date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s", record.Date)
+	}
+	if date.After(v.now()) {
+		return date, fmt.Errorf("date in the future: %s", record.Date)
+	}
+	if len(v.periods) == 0 {
+		if date.Before(earliestDate) {
+			return date, fmt.Errorf("date before %s: %s", earliestDate.Format(dateLayout), record.Date)
+		}
+		return date, nil
+	}
+	if v.period(date) == nil {
+		return date, fmt.Errorf("date outside the legislative periods: %s", record.Date)
+	}
+	return date, nil
+}
+
+// checkChamberSize compares the amount of votes with the seats of the chamber at the given date
+// Sheets that don't list absent legislators may have fewer votes than seats, so fewer votes
+// are only reported when absences are listed:
+func (v *Validator) checkChamberSize(record *vote.Record, date time.Time, chamber string) error {
+	p := v.period(date)
+	if p == nil || chamber == "" {
+		return nil
+	}
+	seats, ok := p.chambers[chamber]
+	if !ok {
+		return nil
+	}
+	count := len(record.Votes)
+	if count > seats {
+		return fmt.Errorf("%d votes but %s has %d seats in %s", count, chamber, seats, p.name)
+	}
+	if count < seats && listsAbsences(record) {
+		return fmt.Errorf("%d votes but %s has %d seats in %s", count, chamber, seats, p.name)
+	}
+	return nil
+}
+
+// listsAbsences reports if the record accounts for absent legislators, legislators
+// that are present but don't vote aren't enough as absences may still be missing:
+func listsAbsences(record *vote.Record) bool {
+	if record.Count()[vote.Absent] > 0 {
+		return true
+	}
+	_, ok := record.Totals[vote.Absent]
+	return ok
+}
+
+// period returns the legislative period that contains the date:
+func (v *Validator) period(date time.Time) *period {
+	for _, p := range v.periods {
+		if !date.Before(p.start) && !date.After(p.end) {
+			return p
+		}
+	}
+	return nil
+}
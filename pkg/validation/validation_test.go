@@ -0,0 +1,171 @@
+package validation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/config"
+	"github.com/matiasinsaurralde/congreso-votaciones/pkg/vote"
+)
+
+// testConfig has a small senate so records stay short:
+var testConfig = &config.Config{
+	LegislativePeriods: []config.LegislativePeriodConfig{
+		{Name: "2018-2023", Start: "2018-07-01", End: "2023-06-30", Chambers: map[string]int{"senadores": 4}},
+		{Name: "2023-2028", Start: "2023-07-01", End: "2028-06-30", Chambers: map[string]int{"senadores": 5}},
+	},
+}
+
+// votes returns a vote per option, named after their position:
+func votes(options ...vote.Option) []*vote.Vote {
+	out := make([]*vote.Vote, 0, len(options))
+	for i, option := range options {
+		out = append(out, &vote.Vote{Name: fmt.Sprintf("Legislador %c", 'A'+i), Option: option})
+	}
+	return out
+}
+
+func newTestValidator(t *testing.T, cfg *config.Config) *Validator {
+	t.Helper()
+	v, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	v.now = func() time.Time {
+		return time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return v
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     *config.Config
+		record  *vote.Record
+		chamber string
+		// checks are the expected failed checks, in order:
+		checks []string
+	}{
+		{
+			name: "valid",
+			record: &vote.Record{
+				Date:   "2023-07-20",
+				Totals: map[vote.Option]int{vote.Yes: 3, vote.No: 2},
+				Votes:  votes(vote.Yes, vote.Yes, vote.Yes, vote.No, vote.No),
+			},
+			chamber: "senadores",
+		},
+		{
+			name: "totals mismatch",
+			record: &vote.Record{
+				Date:   "2023-07-20",
+				Totals: map[vote.Option]int{vote.Yes: 4, vote.No: 1},
+				Votes:  votes(vote.Yes, vote.Yes, vote.Yes, vote.No, vote.No),
+			},
+			checks: []string{CheckTotals, CheckTotals},
+		},
+		{
+			name: "duplicated legislator",
+			record: &vote.Record{
+				Date: "2023-07-20",
+				Votes: append(votes(vote.Yes, vote.No),
+					&vote.Vote{Name: "LEGISLADOR, A", Option: vote.No}),
+			},
+			checks: []string{CheckDuplicates},
+		},
+		{
+			name:   "missing date",
+			record: &vote.Record{Votes: votes(vote.Yes)},
+			checks: []string{CheckDate},
+		},
+		{
+			name:   "invalid date",
+			record: &vote.Record{Date: "20/07/2023", Votes: votes(vote.Yes)},
+			checks: []string{CheckDate},
+		},
+		{
+			name:   "future date",
+			record: &vote.Record{Date: "2024-03-01", Votes: votes(vote.Yes)},
+			checks: []string{CheckDate},
+		},
+		{
+			name:   "outside the legislative periods",
+			record: &vote.Record{Date: "2017-03-01", Votes: votes(vote.Yes)},
+			checks: []string{CheckDate},
+		},
+		{
+			name:   "before the constitution without periods",
+			cfg:    &config.Config{},
+			record: &vote.Record{Date: "1990-03-01", Votes: votes(vote.Yes)},
+			checks: []string{CheckDate},
+		},
+		{
+			name:    "more votes than seats",
+			record:  &vote.Record{Date: "2023-06-30", Votes: votes(vote.Yes, vote.Yes, vote.Yes, vote.No, vote.No)},
+			chamber: "senadores",
+			checks:  []string{CheckChamberSize},
+		},
+		{
+			// The record chamber takes precedence over the document type one:
+			name:    "record chamber",
+			record:  &vote.Record{Chamber: "senadores", Date: "2023-06-30", Votes: votes(vote.Yes, vote.Yes, vote.Yes, vote.No, vote.No)},
+			chamber: "diputados",
+			checks:  []string{CheckChamberSize},
+		},
+		{
+			name:    "fewer votes than seats",
+			record:  &vote.Record{Date: "2023-07-20", Votes: votes(vote.Yes, vote.No, vote.NotVoting)},
+			chamber: "senadores",
+		},
+		{
+			name:    "fewer votes than seats with absences",
+			record:  &vote.Record{Date: "2023-07-20", Votes: votes(vote.Yes, vote.No, vote.Absent)},
+			chamber: "senadores",
+			checks:  []string{CheckChamberSize},
+		},
+		{
+			name:    "unknown chamber",
+			record:  &vote.Record{Date: "2023-07-20", Votes: votes(vote.Yes, vote.No, vote.Absent)},
+			chamber: "diputados",
+		},
+		{
+			name: "several errors",
+			record: &vote.Record{
+				Date:   "2023-06-30",
+				Totals: map[vote.Option]int{vote.Yes: 1},
+				Votes:  append(votes(vote.Yes, vote.Yes, vote.No, vote.No), &vote.Vote{Name: "Legislador A", Option: vote.Yes}),
+			},
+			chamber: "senadores",
+			checks:  []string{CheckTotals, CheckDuplicates, CheckChamberSize},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if cfg == nil {
+				cfg = testConfig
+			}
+			errs := newTestValidator(t, cfg).Validate(tt.record, tt.chamber)
+			checks := make([]string, 0, len(errs))
+			for _, err := range errs {
+				checks = append(checks, err.Check)
+			}
+			if fmt.Sprint(checks) != fmt.Sprint(tt.checks) {
+				t.Errorf("failed checks = %v, want %v: %v", checks, tt.checks, errs)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, periods := range [][]config.LegislativePeriodConfig{
+		{{Name: "bad start", Start: "2018", End: "2023-06-30"}},
+		{{Name: "bad end", Start: "2018-07-01", End: "junio"}},
+		{{Name: "reversed", Start: "2023-06-30", End: "2018-07-01"}},
+	} {
+		if _, err := New(&config.Config{LegislativePeriods: periods}); err == nil {
+			t.Errorf("expected an error for the %s period", periods[0].Name)
+		}
+	}
+}
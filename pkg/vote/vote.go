@@ -0,0 +1,141 @@
+package vote
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Option is a vote option:
+type Option string
+
+// Common vote options:
+const (
+	Yes        Option = "si"
+	No         Option = "no"
+	Abstention Option = "abstencion"
+	Absent     Option = "ausente"
+	NotVoting  Option = "no_vota"
+)
+
+// Record is the data extracted from a vote document:
+type Record struct {
+	// Chamber is the chamber where the vote took place -e.g. "senadores"-:
+	Chamber string `json:"chamber,omitempty"`
+	// Date is the vote date in YYYY-MM-DD format:
+	Date string `json:"date,omitempty"`
+	// Time is the vote time in HH:MM:SS format:
+	Time string `json:"time,omitempty"`
+	// Subject describes what was voted:
+	Subject string `json:"subject,omitempty"`
+	// Totals are the printed totals per option:
+	Totals map[Option]int `json:"totals,omitempty"`
+	// Votes is the list of individual votes:
+	Votes []*Vote `json:"votes"`
+}
+
+// Vote is an individual legislator vote:
+type Vote struct {
+	// Name is the legislator name as printed in the document:
+	Name string `json:"name"`
+	// Option is the vote option:
+	Option Option `json:"option"`
+	// Confidence is an optional confidence score between 0 and 1:
+	Confidence float64 `json:"confidence,omitempty"`
+	// LegislatorID is the ID of the legislator in the registry, set when the name was resolved:
+	LegislatorID string `json:"legislator_id,omitempty"`
+	// Party and Bloc are the organizations the legislator belonged to at the vote date:
+	Party string `json:"party,omitempty"`
+	Bloc  string `json:"bloc,omitempty"`
+}
+
+// Count returns the amount of votes per option:
+func (r *Record) Count() map[Option]int {
+	counts := make(map[Option]int)
+	for _, v := range r.Votes {
+		counts[v.Option]++
+	}
+	return counts
+}
+
+// Field is a single valued field of a record:
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Fields returns the single valued fields of the record -chamber, date, time and subject-:
+func (r *Record) Fields() []Field {
+	return []Field{
+		{"chamber", r.Chamber},
+		{"date", r.Date},
+		{"time", r.Time},
+		{"subject", r.Subject},
+	}
+}
+
+// TotalString returns the printed total of an option, empty when it's not set:
+func (r *Record) TotalString(option Option) string {
+	total, ok := r.Totals[option]
+	if !ok {
+		return ""
+	}
+	return strconv.Itoa(total)
+}
+
+// TotalOptions returns the sorted options with a printed total in any of the records:
+func TotalOptions(records ...*Record) []Option {
+	seen := make(map[Option]bool)
+	options := make([]Option, 0)
+	for _, r := range records {
+		for option := range r.Totals {
+			if !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+	}
+	sort.Slice(options, func(i, j int) bool {
+		return options[i] < options[j]
+	})
+	return options
+}
+
+// VoteByName returns the vote of a legislator, names are compared using NormalizeName:
+func (r *Record) VoteByName(name string) *Vote {
+	normalized := NormalizeName(name)
+	for _, v := range r.Votes {
+		if NormalizeName(v.Name) == normalized {
+			return v
+		}
+	}
+	return nil
+}
+
+// foldReplacer removes accents and other diacritics used in Spanish names:
+var foldReplacer = strings.NewReplacer(
+	"á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u", "ñ", "n",
+	"Á", "a", "É", "e", "Í", "i", "Ó", "o", "Ú", "u", "Ü", "u", "Ñ", "n",
+	",", " ", ".", " ",
+)
+
+// NormalizeName returns a comparable version of a name: lowercase,
+// without accents or punctuation and with its tokens sorted, so
+// "AMARILLA, DIONISIO" and "Dionisio Amarilla" are equivalent:
+func NormalizeName(name string) string {
+	tokens := strings.Fields(FoldName(name))
+	sort.Strings(tokens)
+	return strings.Join(tokens, " ")
+}
+
+// FoldName returns a name in lowercase and without accents or punctuation, keeping its token order:
+func FoldName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(foldReplacer.Replace(name))), " ")
+}
+
+// MotionOption returns the option for the nth motion -starting at 1- when
+// legislators vote between competing motions instead of yes/no:
+func MotionOption(n int) Option {
+	return Option(fmt.Sprintf("mocion_%d", n))
+}